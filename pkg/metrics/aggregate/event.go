@@ -0,0 +1,67 @@
+// Package aggregate collects per-HTTPProxy-instance metrics into a pluggable
+// Sink and lets a designated node aggregate them, across every instance on
+// the cluster, behind a Prometheus /metrics endpoint and a JSON API.
+package aggregate
+
+// Event is published by HTTPProxy's emitMetrics hook on every notable thing
+// that happens while handling a request.
+type Event interface {
+	apply(*LocalSink)
+}
+
+// RequestCompleted records one finished request: its final status code and
+// end-to-end latency.
+type RequestCompleted struct {
+	StatusCode int
+	LatencyMs  float64
+}
+
+func (e RequestCompleted) apply(s *LocalSink) {
+	s.recordRequest(e.StatusCode, e.LatencyMs)
+}
+
+// RateLimiterRejected records one request rejected by HTTPProxy's rateLimiter.
+type RateLimiterRejected struct{}
+
+func (e RateLimiterRejected) apply(s *LocalSink) {
+	s.incRateLimiterRejects()
+}
+
+// CircuitBreakerOpened records one request HTTPProxy's circuitBreaker refused
+// because the breaker was open.
+type CircuitBreakerOpened struct{}
+
+func (e CircuitBreakerOpened) apply(s *LocalSink) {
+	s.incCircuitBreakerOpens()
+}
+
+// FallbackInvoked records one fallback dispatch, keyed by the fallback
+// reason (e.g. "backend", "rateLimiter", "noUpstream").
+type FallbackInvoked struct {
+	Plugin string
+}
+
+func (e FallbackInvoked) apply(s *LocalSink) {
+	s.incFallback(e.Plugin)
+}
+
+// UpstreamResult records one backend round trip to a specific upstream.
+type UpstreamResult struct {
+	Upstream string
+	Success  bool
+}
+
+func (e UpstreamResult) apply(s *LocalSink) {
+	s.recordUpstream(e.Upstream, e.Success)
+}
+
+// Sink receives Events published by HTTPProxy's emitMetrics hook and reports
+// a Snapshot of what it has recorded so far. HTTPProxy holds its sink as
+// this interface (not the concrete *LocalSink) so an alternate
+// implementation, e.g. one that ships Events to a remote collector instead
+// of aggregating them in-process, can be substituted.
+type Sink interface {
+	Publish(Event)
+	Snapshot() Snapshot
+	Close()
+}
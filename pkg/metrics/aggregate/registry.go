@@ -0,0 +1,34 @@
+package aggregate
+
+import "sync"
+
+// instances holds every locally running HTTPProxy's Sink, keyed by its
+// Spec.Server name. pkg/registry's Kind->DefaultSpec registry is for
+// constructor lookup at config-load time, not for tracking live per-instance
+// state, so it doesn't fit here; this is a separate, smaller registry for
+// that purpose. The node's Server reads straight out of this map when it
+// scrapes local instances.
+var instances sync.Map // name string -> Sink
+
+// Register makes name's sink visible to this node's local aggregator scrape.
+// HTTPProxy calls this from New.
+func Register(name string, s Sink) {
+	instances.Store(name, s)
+}
+
+// Deregister removes name. HTTPProxy calls this from Close.
+func Deregister(name string) {
+	instances.Delete(name)
+}
+
+// localSnapshots returns a Snapshot per currently registered instance.
+func localSnapshots() []Snapshot {
+	var snapshots []Snapshot
+	instances.Range(func(key, value interface{}) bool {
+		snap := value.(Sink).Snapshot()
+		snap.Instance = key.(string)
+		snapshots = append(snapshots, snap)
+		return true
+	})
+	return snapshots
+}
@@ -0,0 +1,221 @@
+package aggregate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/megaease/easegateway/pkg/logger"
+)
+
+// Spec describes the aggregation Server run on a single designated node.
+type Spec struct {
+	// Addr is the listen address for the /metrics and JSON API endpoints,
+	// e.g. ":9900".
+	Addr string `yaml:"addr" v:"required"`
+
+	// Peers are other nodes' aggregator addresses (host:port) to pull-scrape
+	// on ScrapeIntervalSec, so the merged view covers the whole cluster
+	// rather than just this node's local instances.
+	Peers []string `yaml:"peers,omitempty"`
+	// ScrapeIntervalSec is the delay between peer scrapes, default 15.
+	ScrapeIntervalSec int `yaml:"scrapeIntervalSec,omitempty"`
+	// ScrapeTimeoutSec is the per-peer scrape timeout, default 5.
+	ScrapeTimeoutSec int `yaml:"scrapeTimeoutSec,omitempty"`
+}
+
+const (
+	defaultScrapeIntervalSec = 15
+	defaultScrapeTimeoutSec  = 5
+)
+
+// Server aggregates this node's locally registered HTTPProxy instances
+// (via Register/Deregister) with periodically pull-scraped peer nodes, and
+// exposes the merged result over HTTP.
+type Server struct {
+	spec *Spec
+
+	httpServer *http.Server
+	client     *http.Client
+
+	mu        sync.RWMutex
+	peerCache map[string][]Snapshot
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewServer creates and starts a Server.
+func NewServer(spec *Spec) *Server {
+	s := &Server{
+		spec:      spec,
+		client:    &http.Client{Timeout: timeoutOrDefault(spec.ScrapeTimeoutSec)},
+		peerCache: map[string][]Snapshot{},
+		done:      make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.handlePrometheus)
+	mux.HandleFunc("/api/snapshots", s.handleJSON)
+	mux.HandleFunc("/api/push", s.handlePush)
+	s.httpServer = &http.Server{Addr: spec.Addr, Handler: mux}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("aggregate: server on %s stopped: %v", spec.Addr, err)
+		}
+	}()
+
+	if len(spec.Peers) > 0 {
+		s.wg.Add(1)
+		go s.scrapePeersLoop()
+	}
+
+	return s
+}
+
+func timeoutOrDefault(sec int) time.Duration {
+	if sec <= 0 {
+		sec = defaultScrapeTimeoutSec
+	}
+	return time.Duration(sec) * time.Second
+}
+
+func (s *Server) scrapePeersLoop() {
+	defer s.wg.Done()
+
+	interval := time.Duration(s.spec.ScrapeIntervalSec) * time.Second
+	if interval <= 0 {
+		interval = defaultScrapeIntervalSec * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			for _, peer := range s.spec.Peers {
+				snapshots, err := s.scrapePeer(peer)
+				if err != nil {
+					logger.Errorf("aggregate: scrape peer %s failed: %v", peer, err)
+					continue
+				}
+				s.mu.Lock()
+				s.peerCache[peer] = snapshots
+				s.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (s *Server) scrapePeer(peer string) ([]Snapshot, error) {
+	resp, err := s.client.Get("http://" + peer + "/api/snapshots")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var snapshots []Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshots); err != nil {
+		return nil, fmt.Errorf("decode peer response: %w", err)
+	}
+	return snapshots, nil
+}
+
+// allSnapshots returns every known instance's Snapshot: this node's local
+// instances plus the last successful scrape of each peer.
+func (s *Server) allSnapshots() []Snapshot {
+	snapshots := localSnapshots()
+
+	s.mu.RLock()
+	for _, peerSnapshots := range s.peerCache {
+		snapshots = append(snapshots, peerSnapshots...)
+	}
+	s.mu.RUnlock()
+
+	return snapshots
+}
+
+func (s *Server) handleJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.allSnapshots())
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	var snap Snapshot
+	if err := json.NewDecoder(r.Body).Decode(&snap); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.peerCache["push:"+snap.Instance] = []Snapshot{snap}
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handlePrometheus(w http.ResponseWriter, r *http.Request) {
+	merged := Merge(s.allSnapshots())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# HELP easegateway_request_rate1 1-minute request rate, summed across instances.\n")
+	fmt.Fprintf(w, "# TYPE easegateway_request_rate1 gauge\n")
+	fmt.Fprintf(w, "easegateway_request_rate1 %f\n", merged.RequestRate1)
+
+	fmt.Fprintf(w, "# HELP easegateway_status_codes_total Responses by status code.\n")
+	fmt.Fprintf(w, "# TYPE easegateway_status_codes_total counter\n")
+	for code, count := range merged.StatusCodes {
+		fmt.Fprintf(w, "easegateway_status_codes_total{code=\"%d\"} %d\n", code, count)
+	}
+
+	fmt.Fprintf(w, "# HELP easegateway_latency_ms Latency percentiles in milliseconds.\n")
+	fmt.Fprintf(w, "# TYPE easegateway_latency_ms gauge\n")
+	fmt.Fprintf(w, "easegateway_latency_ms{quantile=\"0.5\"} %f\n", merged.LatencyP50Ms)
+	fmt.Fprintf(w, "easegateway_latency_ms{quantile=\"0.95\"} %f\n", merged.LatencyP95Ms)
+	fmt.Fprintf(w, "easegateway_latency_ms{quantile=\"0.99\"} %f\n", merged.LatencyP99Ms)
+
+	fmt.Fprintf(w, "# HELP easegateway_rate_limiter_rejects_total Requests rejected by rateLimiter.\n")
+	fmt.Fprintf(w, "# TYPE easegateway_rate_limiter_rejects_total counter\n")
+	fmt.Fprintf(w, "easegateway_rate_limiter_rejects_total %d\n", merged.RateLimiterRejects)
+
+	fmt.Fprintf(w, "# HELP easegateway_circuit_breaker_opens_total Requests refused by an open circuitBreaker.\n")
+	fmt.Fprintf(w, "# TYPE easegateway_circuit_breaker_opens_total counter\n")
+	fmt.Fprintf(w, "easegateway_circuit_breaker_opens_total %d\n", merged.CircuitBreakerOpens)
+
+	fmt.Fprintf(w, "# HELP easegateway_fallback_invocations_total Fallback dispatches by plugin.\n")
+	fmt.Fprintf(w, "# TYPE easegateway_fallback_invocations_total counter\n")
+	for plugin, count := range merged.FallbackInvocations {
+		fmt.Fprintf(w, "easegateway_fallback_invocations_total{plugin=\"%s\"} %d\n", plugin, count)
+	}
+
+	fmt.Fprintf(w, "# HELP easegateway_upstream_requests_total Backend requests by upstream and outcome.\n")
+	fmt.Fprintf(w, "# TYPE easegateway_upstream_requests_total counter\n")
+	for upstream, count := range merged.UpstreamSuccess {
+		fmt.Fprintf(w, "easegateway_upstream_requests_total{upstream=\"%s\",outcome=\"success\"} %d\n", upstream, count)
+	}
+	for upstream, count := range merged.UpstreamError {
+		fmt.Fprintf(w, "easegateway_upstream_requests_total{upstream=\"%s\",outcome=\"error\"} %d\n", upstream, count)
+	}
+}
+
+// Close stops the Server's HTTP listener and peer-scrape loop.
+func (s *Server) Close() {
+	close(s.done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		logger.Errorf("aggregate: shutdown failed: %v", err)
+	}
+
+	s.wg.Wait()
+}
@@ -0,0 +1,159 @@
+package aggregate
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// latencyBucketBoundsMs are the upper bounds, in milliseconds, of a fixed
+// set of exponential latency buckets (plus one implicit overflow bucket for
+// anything above the last bound). Counting into these buckets instead of
+// keeping raw samples lets Merge combine instances by summing bucket
+// counts and recomputing percentiles, rather than averaging each
+// instance's already-computed percentile, which is not a valid aggregation.
+var latencyBucketBoundsMs = buildLatencyBucketBounds()
+
+func buildLatencyBucketBounds() []float64 {
+	var bounds []float64
+	for ms := 1.0; ms < 60000; ms *= 1.5 {
+		bounds = append(bounds, ms)
+	}
+	return bounds
+}
+
+func latencyBucketIndex(latencyMs float64) int {
+	return sort.Search(len(latencyBucketBoundsMs), func(i int) bool {
+		return latencyBucketBoundsMs[i] > latencyMs
+	})
+}
+
+const rate1TickInterval = 5 * time.Second
+
+// LocalSink is the in-process default Sink for one HTTPProxy instance. It is
+// cheap enough to update on every request and is what the local aggregator
+// (or a remote one, via Snapshot's JSON form) reads.
+type LocalSink struct {
+	rate1    metrics.EWMA
+	tickDone chan struct{}
+
+	mu                  sync.Mutex
+	statusCodes         map[int]int64
+	fallbackInvocations map[string]int64
+	upstreamSuccess     map[string]int64
+	upstreamError       map[string]int64
+
+	latencyBuckets []int64 // len(latencyBucketBoundsMs)+1; accessed atomically
+
+	rateLimiterRejects  int64
+	circuitBreakerOpens int64
+}
+
+// NewLocalSink creates a LocalSink and starts its background rate ticker;
+// call Close to stop it.
+func NewLocalSink() *LocalSink {
+	s := &LocalSink{
+		rate1:               metrics.NewEWMA1(),
+		tickDone:            make(chan struct{}),
+		statusCodes:         map[int]int64{},
+		fallbackInvocations: map[string]int64{},
+		upstreamSuccess:     map[string]int64{},
+		upstreamError:       map[string]int64{},
+		latencyBuckets:      make([]int64, len(latencyBucketBoundsMs)+1),
+	}
+	go s.tickRate1()
+	return s
+}
+
+func (s *LocalSink) tickRate1() {
+	ticker := time.NewTicker(rate1TickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.tickDone:
+			return
+		case <-ticker.C:
+			s.rate1.Tick()
+		}
+	}
+}
+
+// Close stops the LocalSink's background rate ticker. HTTPProxy calls this
+// from Close.
+func (s *LocalSink) Close() {
+	close(s.tickDone)
+}
+
+// Publish implements Sink.
+func (s *LocalSink) Publish(e Event) {
+	e.apply(s)
+}
+
+func (s *LocalSink) recordRequest(statusCode int, latencyMs float64) {
+	s.rate1.Update(1)
+
+	s.mu.Lock()
+	s.statusCodes[statusCode]++
+	s.mu.Unlock()
+
+	atomic.AddInt64(&s.latencyBuckets[latencyBucketIndex(latencyMs)], 1)
+}
+
+func (s *LocalSink) incRateLimiterRejects() {
+	atomic.AddInt64(&s.rateLimiterRejects, 1)
+}
+
+func (s *LocalSink) incCircuitBreakerOpens() {
+	atomic.AddInt64(&s.circuitBreakerOpens, 1)
+}
+
+func (s *LocalSink) incFallback(plugin string) {
+	s.mu.Lock()
+	s.fallbackInvocations[plugin]++
+	s.mu.Unlock()
+}
+
+func (s *LocalSink) recordUpstream(upstream string, success bool) {
+	s.mu.Lock()
+	if success {
+		s.upstreamSuccess[upstream]++
+	} else {
+		s.upstreamError[upstream]++
+	}
+	s.mu.Unlock()
+}
+
+// Snapshot returns the current metrics. It implements Sink, which the
+// Server reads for same-process instances.
+func (s *LocalSink) Snapshot() Snapshot {
+	snap := newSnapshot()
+	snap.RequestRate1 = s.rate1.Rate1()
+	snap.RateLimiterRejects = atomic.LoadInt64(&s.rateLimiterRejects)
+	snap.CircuitBreakerOpens = atomic.LoadInt64(&s.circuitBreakerOpens)
+
+	s.mu.Lock()
+	for code, count := range s.statusCodes {
+		snap.StatusCodes[code] = count
+	}
+	for plugin, count := range s.fallbackInvocations {
+		snap.FallbackInvocations[plugin] = count
+	}
+	for upstream, count := range s.upstreamSuccess {
+		snap.UpstreamSuccess[upstream] = count
+	}
+	for upstream, count := range s.upstreamError {
+		snap.UpstreamError[upstream] = count
+	}
+	s.mu.Unlock()
+
+	for i := range s.latencyBuckets {
+		snap.LatencyBuckets[i] = atomic.LoadInt64(&s.latencyBuckets[i])
+	}
+	snap.LatencyP50Ms, snap.LatencyP95Ms, snap.LatencyP99Ms = percentilesFromBuckets(snap.LatencyBuckets)
+
+	return snap
+}
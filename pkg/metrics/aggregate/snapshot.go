@@ -0,0 +1,109 @@
+package aggregate
+
+// Snapshot is a point-in-time view of one instance's (or, once merged, a
+// whole cluster's) metrics. It is the JSON wire format for both the push
+// and pull paths.
+type Snapshot struct {
+	Instance string `json:"instance,omitempty"`
+
+	RequestRate1 float64       `json:"requestRate1"`
+	StatusCodes  map[int]int64 `json:"statusCodes"`
+
+	// LatencyBuckets holds per-bucket request counts aligned with
+	// latencyBucketBoundsMs (plus one trailing overflow bucket). It is the
+	// mergeable form of the latency distribution; LatencyP50Ms/P95Ms/P99Ms
+	// are estimates derived from it for convenience.
+	LatencyBuckets []int64 `json:"latencyBuckets"`
+	LatencyP50Ms   float64 `json:"latencyP50Ms"`
+	LatencyP95Ms   float64 `json:"latencyP95Ms"`
+	LatencyP99Ms   float64 `json:"latencyP99Ms"`
+
+	RateLimiterRejects  int64            `json:"rateLimiterRejects"`
+	CircuitBreakerOpens int64            `json:"circuitBreakerOpens"`
+	FallbackInvocations map[string]int64 `json:"fallbackInvocations"`
+	UpstreamSuccess     map[string]int64 `json:"upstreamSuccess"`
+	UpstreamError       map[string]int64 `json:"upstreamError"`
+}
+
+func newSnapshot() Snapshot {
+	return Snapshot{
+		StatusCodes:         map[int]int64{},
+		LatencyBuckets:      make([]int64, len(latencyBucketBoundsMs)+1),
+		FallbackInvocations: map[string]int64{},
+		UpstreamSuccess:     map[string]int64{},
+		UpstreamError:       map[string]int64{},
+	}
+}
+
+// percentilesFromBuckets estimates p50/p95/p99 from bucket counts aligned
+// with latencyBucketBoundsMs (plus a trailing overflow bucket), reporting
+// the landing bucket's upper bound as the estimate for each percentile.
+func percentilesFromBuckets(buckets []int64) (p50, p95, p99 float64) {
+	var total int64
+	for _, count := range buckets {
+		total += count
+	}
+	if total == 0 {
+		return 0, 0, 0
+	}
+
+	return bucketBoundAtRank(buckets, total, 0.50),
+		bucketBoundAtRank(buckets, total, 0.95),
+		bucketBoundAtRank(buckets, total, 0.99)
+}
+
+func bucketBoundAtRank(buckets []int64, total int64, p float64) float64 {
+	target := int64(p * float64(total))
+
+	var cum int64
+	for i, count := range buckets {
+		cum += count
+		if cum > target {
+			if i < len(latencyBucketBoundsMs) {
+				return latencyBucketBoundsMs[i]
+			}
+			break // overflow bucket: fall through to the last finite bound
+		}
+	}
+	return latencyBucketBoundsMs[len(latencyBucketBoundsMs)-1]
+}
+
+// Merge combines snapshots from every known instance into one cluster-wide
+// view. Latency buckets are summed and percentiles are recomputed from the
+// merged buckets; averaging each instance's already-computed percentile
+// would dilute a single hot instance's P99 into irrelevance, which is not a
+// valid aggregation.
+func Merge(snapshots []Snapshot) Snapshot {
+	merged := newSnapshot()
+	if len(snapshots) == 0 {
+		return merged
+	}
+
+	for _, s := range snapshots {
+		merged.RequestRate1 += s.RequestRate1
+		merged.RateLimiterRejects += s.RateLimiterRejects
+		merged.CircuitBreakerOpens += s.CircuitBreakerOpens
+
+		for code, count := range s.StatusCodes {
+			merged.StatusCodes[code] += count
+		}
+		for plugin, count := range s.FallbackInvocations {
+			merged.FallbackInvocations[plugin] += count
+		}
+		for upstream, count := range s.UpstreamSuccess {
+			merged.UpstreamSuccess[upstream] += count
+		}
+		for upstream, count := range s.UpstreamError {
+			merged.UpstreamError[upstream] += count
+		}
+		for i, count := range s.LatencyBuckets {
+			if i < len(merged.LatencyBuckets) {
+				merged.LatencyBuckets[i] += count
+			}
+		}
+	}
+
+	merged.LatencyP50Ms, merged.LatencyP95Ms, merged.LatencyP99Ms = percentilesFromBuckets(merged.LatencyBuckets)
+
+	return merged
+}
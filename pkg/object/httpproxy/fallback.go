@@ -0,0 +1,71 @@
+package httpproxy
+
+import (
+	"strings"
+
+	"github.com/megaease/easegateway/pkg/context"
+)
+
+// fallbackPlugin identifies which stage of Handle triggered a fallback
+// dispatch, so a configured fallback can tell them apart if it ever needs to.
+type fallbackPlugin string
+
+const (
+	// fallbackPluginBackend marks a fallback triggered by the backend (or
+	// fastcgiBackend) round trip.
+	fallbackPluginBackend fallbackPlugin = "backend"
+	// fallbackPluginRateLimiter marks a fallback triggered by rateLimiter
+	// rejecting the request.
+	fallbackPluginRateLimiter fallbackPlugin = "rateLimiter"
+	// fallbackPluginCircuitBreaker marks a fallback triggered by an open
+	// circuitBreaker.
+	fallbackPluginCircuitBreaker fallbackPlugin = "circuitBreaker"
+	// fallbackPluginCandidateBackend marks a fallback triggered while routing
+	// through candidateBackend.
+	fallbackPluginCandidateBackend fallbackPlugin = "candidateBackend"
+	// fallbackPluginNoUpstream marks a fallback triggered by backend.Handle
+	// finding no healthy upstream in its pool.
+	fallbackPluginNoUpstream fallbackPlugin = "noUpstream"
+	// fallbackPluginOutboundProxy marks a fallback triggered by the backend
+	// having no healthy outbound proxy to reach its upstream through.
+	fallbackPluginOutboundProxy fallbackPlugin = "outboundProxy"
+)
+
+// proxyFallbackSpec configures the canned response HTTPProxy serves in place
+// of a failed stage of Handle.
+type proxyFallbackSpec struct {
+	// MockCode is the status code returned to the client when falling back.
+	MockCode int `yaml:"mockCode,omitempty"`
+	// MockBody is the response body returned to the client when falling back.
+	MockBody string `yaml:"mockBody,omitempty"`
+}
+
+// proxyFallback serves Spec.Fallback's canned response in place of a failed
+// stage of Handle.
+type proxyFallback struct {
+	spec *proxyFallbackSpec
+}
+
+// newProxyFallback creates a proxyFallback. runtime is unused today; it
+// exists for the same reload-continuity symmetry as the other sub-plugins'
+// New functions.
+func newProxyFallback(spec *proxyFallbackSpec, runtime *proxyFallback) *proxyFallback {
+	return &proxyFallback{spec: spec}
+}
+
+// tryFallback serves the canned response if err is non-nil; pt is accepted
+// for parity with tryFallback's caller but is not otherwise acted on yet.
+func (f *proxyFallback) tryFallback(ctx context.HTTPContext, pt fallbackPlugin, err error) {
+	if err == nil {
+		return
+	}
+
+	w := ctx.Response()
+	if f.spec.MockCode != 0 {
+		w.SetStatusCode(f.spec.MockCode)
+	}
+	w.SetBody(strings.NewReader(f.spec.MockBody))
+	ctx.Cancel(err)
+}
+
+func (f *proxyFallback) close() {}
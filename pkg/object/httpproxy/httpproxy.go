@@ -1,16 +1,23 @@
 package httpproxy
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/megaease/easegateway/pkg/context"
+	"github.com/megaease/easegateway/pkg/logger"
+	"github.com/megaease/easegateway/pkg/metrics/aggregate"
 	"github.com/megaease/easegateway/pkg/plugin/adaptor"
 	"github.com/megaease/easegateway/pkg/plugin/backend"
 	"github.com/megaease/easegateway/pkg/plugin/candidatebackend"
 	"github.com/megaease/easegateway/pkg/plugin/circuitbreaker"
 	"github.com/megaease/easegateway/pkg/plugin/compression"
+	"github.com/megaease/easegateway/pkg/plugin/fastcgibackend"
 	"github.com/megaease/easegateway/pkg/plugin/mirrorbackend"
+	"github.com/megaease/easegateway/pkg/plugin/oidcauth"
+	"github.com/megaease/easegateway/pkg/plugin/outboundproxy"
 	"github.com/megaease/easegateway/pkg/plugin/ratelimiter"
 	"github.com/megaease/easegateway/pkg/plugin/validator"
 	"github.com/megaease/easegateway/pkg/registry"
@@ -33,8 +40,11 @@ type (
 		spec  *Spec
 		rate1 metrics.EWMA
 
+		metricsSink aggregate.Sink
+
 		fallback *proxyFallback
 
+		oidcAuth         *oidcauth.OIDCAuth
 		validator        *validator.Validator
 		rateLimiter      *ratelimiter.RateLimiter
 		circuitBreaker   *circuitbreaker.CircuitBreaker
@@ -42,6 +52,8 @@ type (
 		mirrorBackend    *mirrorbackend.MirrorBackend
 		candidateBackend *candidatebackend.CandidateBackend
 		backend          *backend.Backend
+		fastcgiBackend   *fastcgibackend.FastCGIBackend
+		outboundProxy    *outboundproxy.OutboundProxy
 		compression      *compression.Compression
 	}
 
@@ -52,14 +64,18 @@ type (
 
 		Fallback *proxyFallbackSpec `yaml:"fallback,omitempty"`
 
+		OIDCAuth         *oidcauth.Spec         `yaml:"oidcAuth,omitempty"`
 		Validator        *validator.Spec        `yaml:"validator,omitempty"`
 		RateLimiter      *ratelimiter.Spec      `yaml:"rateLimiter,omitempty"`
 		CircuitBreaker   *circuitbreaker.Spec   `yaml:"circuitBreaker,omitempty"`
 		Adaptor          *adaptor.Spec          `yaml:"adaptor,omitempty"`
 		MirrorBackend    *mirrorbackend.Spec    `yaml:"mirrorBackend,omitempty"`
 		CandidateBackend *candidatebackend.Spec `yaml:"candidateBackend,omitempty"`
-		Backend          *backend.Spec          `yaml:"backend" v:"required"`
-		Compression      *compression.Spec      `yaml:"compression,omitempty"`
+		// Backend and FastCGIBackend are mutually exclusive; exactly one must be set.
+		Backend        *backend.Spec        `yaml:"backend,omitempty" v:"required_without=FastCGIBackend,excluded_with=FastCGIBackend"`
+		FastCGIBackend *fastcgibackend.Spec `yaml:"fastcgiBackend,omitempty" v:"required_without=Backend,excluded_with=Backend"`
+		OutboundProxy  *outboundproxy.Spec  `yaml:"outboundProxy,omitempty"`
+		Compression    *compression.Spec    `yaml:"compression,omitempty"`
 	}
 )
 
@@ -68,14 +84,20 @@ func New(spec *Spec, runtime *Runtime) *HTTPProxy {
 	runtime.reload(spec)
 
 	hp := &HTTPProxy{
-		spec:  spec,
-		rate1: runtime.rate1,
+		spec:        spec,
+		rate1:       runtime.rate1,
+		metricsSink: aggregate.NewLocalSink(),
 	}
+	aggregate.Register(spec.Server, hp.metricsSink)
 
 	if spec.Fallback != nil {
 		hp.fallback = newProxyFallback(spec.Fallback, runtime.fallback)
 	}
 
+	if spec.OIDCAuth != nil {
+		hp.oidcAuth = oidcauth.New(spec.OIDCAuth)
+	}
+
 	if spec.Validator != nil {
 		hp.validator = validator.New(spec.Validator, runtime.validator)
 	}
@@ -96,14 +118,37 @@ func New(spec *Spec, runtime *Runtime) *HTTPProxy {
 		hp.candidateBackend = candidatebackend.New(spec.CandidateBackend, runtime.candidateBackend)
 	}
 
-	hp.backend = backend.New(spec.Backend, runtime.backend)
+	if spec.OutboundProxy != nil {
+		op, err := outboundproxy.New(spec.OutboundProxy)
+		if err != nil {
+			logger.Errorf("httpproxy: %s: new outboundproxy failed: %v", spec.Server, err)
+		} else {
+			hp.outboundProxy = op
+		}
+	}
+
+	if spec.FastCGIBackend != nil {
+		hp.fastcgiBackend = fastcgibackend.New(spec.FastCGIBackend)
+	} else {
+		hp.backend = backend.New(spec.Backend, runtime.backend)
+		if hp.outboundProxy != nil {
+			hp.backend.SetTransport(hp.outboundProxy.Transport())
+		}
+		hp.backend.OnUpstreamResult(func(upstream string, success bool) {
+			hp.emitMetrics(aggregate.UpstreamResult{Upstream: upstream, Success: success})
+		})
+	}
 
 	if spec.Compression != nil {
 		hp.compression = compression.New(spec.Compression, runtime.compression)
 		if hp.candidateBackend != nil {
 			hp.candidateBackend.OnResponseGot(hp.compression.Compress)
 		}
-		hp.backend.OnResponseGot(hp.compression.Compress)
+		if hp.fastcgiBackend != nil {
+			hp.fastcgiBackend.OnResponseGot(hp.compression.Compress)
+		} else {
+			hp.backend.OnResponseGot(hp.compression.Compress)
+		}
 	}
 
 	return hp
@@ -117,8 +162,13 @@ func DefaultSpec() registry.Spec {
 
 // Handle handles all incoming traffic.
 func (hp *HTTPProxy) Handle(ctx context.HTTPContext) {
+	start := time.Now()
 	defer ctx.OnFinish(func() {
 		hp.rate1.Update(1)
+		hp.emitMetrics(aggregate.RequestCompleted{
+			StatusCode: ctx.Response().StatusCode(),
+			LatencyMs:  float64(time.Since(start).Milliseconds()),
+		})
 	})
 
 	hp.preHandle(ctx)
@@ -137,6 +187,13 @@ func (hp *HTTPProxy) Handle(ctx context.HTTPContext) {
 func (hp *HTTPProxy) preHandle(ctx context.HTTPContext) {
 	w := ctx.Response()
 
+	if hp.oidcAuth != nil {
+		hp.oidcAuth.Handle(ctx)
+		if ctx.Cancelled() {
+			return
+		}
+	}
+
 	if hp.validator != nil {
 		err := hp.validator.Validate(ctx)
 		if err != nil {
@@ -151,6 +208,7 @@ func (hp *HTTPProxy) preHandle(ctx context.HTTPContext) {
 		err := hp.rateLimiter.Limit(ctx)
 		if err != nil {
 			w.SetStatusCode(http.StatusTooManyRequests)
+			hp.emitMetrics(aggregate.RateLimiterRejected{})
 			// NOTICE: Return regardless of result.
 			hp.tryFallback(ctx, fallbackPluginRateLimiter, err)
 			return
@@ -167,24 +225,43 @@ func (hp *HTTPProxy) preHandle(ctx context.HTTPContext) {
 }
 
 func (hp *HTTPProxy) handle(ctx context.HTTPContext) {
-	pt, handler := fallbackPluginBackend, hp.backend.Handle
+	// backendHandle is the error-returning Handle of whichever backend this
+	// request goes to, so the switch below can tell a no-healthy-upstream /
+	// no-proxy-available condition apart from a plain proxy error.
+	var err error
+	pt, backendHandle := fallbackPluginBackend, hp.backend.Handle
+	if hp.fastcgiBackend != nil {
+		pt, backendHandle = fallbackPluginBackend, hp.fastcgiBackend.Handle
+	}
+	handler := func(ctx context.HTTPContext) { err = backendHandle(ctx) }
+
 	if hp.candidateBackend != nil && hp.candidateBackend.Filter(ctx) {
 		pt, handler = fallbackPluginCandidateBackend, hp.candidateBackend.Handle
 	}
 
 	if hp.circuitBreaker != nil {
-		err := hp.circuitBreaker.Protect(ctx, handler)
-		if err != nil {
+		cbErr := hp.circuitBreaker.Protect(ctx, handler)
+		if cbErr != nil {
 			ctx.Response().SetStatusCode(http.StatusServiceUnavailable)
-			hp.tryFallback(ctx, fallbackPluginCircuitBreaker, err)
-		} else {
-			hp.tryFallback(ctx, pt, nil /*error*/)
+			hp.emitMetrics(aggregate.CircuitBreakerOpened{})
+			hp.tryFallback(ctx, fallbackPluginCircuitBreaker, cbErr)
+			return
 		}
 	} else {
 		handler(ctx)
-		hp.tryFallback(ctx, pt, nil /*error*/)
 	}
 
+	// A backend with no healthy upstream, or no healthy outbound proxy to
+	// reach it through, gets its own fallback reason so the fallback metrics
+	// tell those apart from a plain proxy error; proxyFallback serves the
+	// same canned response for every reason.
+	switch {
+	case errors.Is(err, backend.ErrNoHealthyUpstream):
+		pt = fallbackPluginNoUpstream
+	case errors.Is(err, outboundproxy.ErrNoProxyAvailable):
+		pt = fallbackPluginOutboundProxy
+	}
+	hp.tryFallback(ctx, pt, err)
 }
 
 func (hp *HTTPProxy) postHandle(ctx context.HTTPContext) {
@@ -194,6 +271,10 @@ func (hp *HTTPProxy) postHandle(ctx context.HTTPContext) {
 }
 
 func (hp *HTTPProxy) tryFallback(ctx context.HTTPContext, pt fallbackPlugin, err error) {
+	if err != nil {
+		hp.emitMetrics(aggregate.FallbackInvoked{Plugin: fmt.Sprintf("%v", pt)})
+	}
+
 	if hp.fallback != nil {
 		hp.fallback.tryFallback(ctx, pt, err)
 	} else if err != nil {
@@ -201,11 +282,23 @@ func (hp *HTTPProxy) tryFallback(ctx context.HTTPContext, pt fallbackPlugin, err
 	}
 }
 
+// emitMetrics publishes ev to this instance's metrics sink, so the cluster
+// aggregator's local scrape (see pkg/metrics/aggregate) sees it.
+func (hp *HTTPProxy) emitMetrics(ev aggregate.Event) {
+	hp.metricsSink.Publish(ev)
+}
+
 // Close closes HTTPProxy.
 func (hp *HTTPProxy) Close() {
+	aggregate.Deregister(hp.spec.Server)
+	hp.metricsSink.Close()
+
 	if hp.fallback != nil {
 		hp.fallback.close()
 	}
+	if hp.oidcAuth != nil {
+		hp.oidcAuth.Close()
+	}
 	if hp.validator != nil {
 		hp.validator.Close()
 	}
@@ -225,7 +318,14 @@ func (hp *HTTPProxy) Close() {
 		hp.candidateBackend.Close()
 	}
 
-	hp.backend.Close()
+	if hp.fastcgiBackend != nil {
+		hp.fastcgiBackend.Close()
+	} else {
+		hp.backend.Close()
+	}
+	if hp.outboundProxy != nil {
+		hp.outboundProxy.Close()
+	}
 
 	if hp.compression != nil {
 		hp.compression.Close()
@@ -0,0 +1,201 @@
+// Package backend is the default HTTP upstream transport for HTTPProxy. It
+// load balances across Spec.Targets using a pluggable selection policy and,
+// when configured, removes unhealthy targets from the pool via active
+// health checks.
+package backend
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/megaease/easegateway/pkg/context"
+)
+
+// ErrNoHealthyUpstream is returned by Handle when every upstream has been
+// taken out of the pool by the health checker (or none are configured
+// healthy), so HTTPProxy can tell this apart from a plain proxy error.
+var ErrNoHealthyUpstream = errors.New("backend: no healthy upstream")
+
+type (
+	// Backend is Object Backend, the default upstream transport for HTTPProxy.
+	Backend struct {
+		spec *Spec
+
+		upstreams []*upstream
+		select_   selector
+		checker   *healthChecker
+
+		transport http.RoundTripper
+
+		onResponseGot    []ResponseGotFunc
+		onUpstreamResult []UpstreamResultFunc
+	}
+
+	// ResponseGotFunc is called after a response has been read back into
+	// ctx.Response().
+	ResponseGotFunc func(ctx context.HTTPContext)
+
+	// UpstreamResultFunc is called after Handle has proxied a request to
+	// upstream, with whether the round trip succeeded.
+	UpstreamResultFunc func(upstream string, success bool)
+)
+
+// New creates a Backend.
+func New(spec *Spec, runtime *Runtime) *Backend {
+	b := &Backend{
+		spec:      spec,
+		select_:   newSelector(spec),
+		transport: http.DefaultTransport,
+	}
+
+	for _, target := range spec.Targets {
+		b.upstreams = append(b.upstreams, newUpstream(target))
+	}
+
+	if spec.HealthCheck != nil {
+		b.checker = newHealthChecker(spec.HealthCheck, b.upstreams)
+		go b.checker.run()
+	}
+
+	return b
+}
+
+// SetTransport overrides the http.RoundTripper used to reach upstreams,
+// e.g. with outboundproxy.OutboundProxy's Transport().
+func (b *Backend) SetTransport(transport http.RoundTripper) {
+	b.transport = transport
+}
+
+// OnResponseGot registers fn to run after a response has been written into
+// ctx.Response().
+func (b *Backend) OnResponseGot(fn ResponseGotFunc) {
+	b.onResponseGot = append(b.onResponseGot, fn)
+}
+
+// OnUpstreamResult registers fn to run after Handle has proxied a request,
+// reporting the upstream used and whether the round trip succeeded.
+func (b *Backend) OnUpstreamResult(fn UpstreamResultFunc) {
+	b.onUpstreamResult = append(b.onUpstreamResult, fn)
+}
+
+// Handle selects a healthy upstream per Spec.Policy and proxies the request
+// to it. It returns ErrNoHealthyUpstream if the pool is empty, or a wrapped
+// proxy error otherwise; both are also recorded on ctx via ctx.Cancel.
+func (b *Backend) Handle(ctx context.HTTPContext) error {
+	candidates := b.healthyUpstreams()
+
+	u := b.select_(ctx, candidates)
+	if u == nil {
+		ctx.Response().SetStatusCode(http.StatusServiceUnavailable)
+		ctx.Cancel(ErrNoHealthyUpstream)
+		return ErrNoHealthyUpstream
+	}
+
+	u.incInFlight()
+	defer u.decInFlight()
+
+	if err := b.proxy(ctx, u); err != nil {
+		for _, fn := range b.onUpstreamResult {
+			fn(u.url, false)
+		}
+		err = fmt.Errorf("backend: %w", err)
+		ctx.Response().SetStatusCode(http.StatusBadGateway)
+		ctx.Cancel(err)
+		return err
+	}
+	for _, fn := range b.onUpstreamResult {
+		fn(u.url, true)
+	}
+
+	if b.spec.Policy == PolicyCookie {
+		name := b.spec.CookieName
+		if name == "" {
+			name = defaultCookieName
+		}
+		ctx.Response().SetCookie(&http.Cookie{Name: name, Value: u.url})
+	}
+
+	for _, fn := range b.onResponseGot {
+		fn(ctx)
+	}
+	return nil
+}
+
+// healthyUpstreams returns the subset of upstreams currently in the pool. If
+// no health check is configured, every upstream is considered healthy.
+func (b *Backend) healthyUpstreams() []*upstream {
+	if b.checker == nil {
+		return b.upstreams
+	}
+
+	healthy := make([]*upstream, 0, len(b.upstreams))
+	for _, u := range b.upstreams {
+		if u.isHealthy() {
+			healthy = append(healthy, u)
+		}
+	}
+	return healthy
+}
+
+func (b *Backend) proxy(ctx context.HTTPContext, u *upstream) error {
+	target, err := url.Parse(u.url)
+	if err != nil {
+		return fmt.Errorf("parse upstream %s: %w", u.url, err)
+	}
+
+	// Build the request from the incoming path alone and leave joining it
+	// onto target's path to the reverse proxy's Director; prepending
+	// target.String() here would double target.Path when it is non-root
+	// (e.g. a Targets entry like "http://10.0.0.1:8080/api/v1").
+	req := ctx.Request()
+	r, err := http.NewRequest(req.Method(), req.Path(), req.Body())
+	if err != nil {
+		return err
+	}
+	r.URL.RawQuery = req.Query()
+	r.Header = req.Header().H()
+
+	// Reuse the reverse proxy's Director to rewrite scheme/host/path the
+	// same way ServeHTTP would, but round-trip manually so we get back a
+	// real *http.Response/error instead of having it flushed to the
+	// client behind our backs: Handle needs the error to report upstream
+	// failures via onUpstreamResult, and the caller needs a chance to
+	// still mutate ctx.Response() (sticky cookie, OnResponseGot hooks)
+	// before anything is written out.
+	rp := httputil.NewSingleHostReverseProxy(target)
+	rp.Director(r)
+
+	resp, err := b.transport.RoundTrip(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read upstream response: %w", err)
+	}
+
+	w := ctx.Response()
+	w.SetStatusCode(resp.StatusCode)
+	for name, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.SetBody(bytes.NewReader(body))
+
+	return nil
+}
+
+// Close closes the Backend, stopping its health checker if any.
+func (b *Backend) Close() {
+	if b.checker != nil {
+		b.checker.close()
+	}
+}
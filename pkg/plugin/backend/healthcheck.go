@@ -0,0 +1,118 @@
+package backend
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultIntervalSec        = 10
+	defaultTimeoutSec         = 3
+	defaultUnhealthyThreshold = 3
+	defaultHealthyThreshold   = 2
+	defaultExpectedStatus     = "2.."
+)
+
+// healthChecker actively probes every upstream on an interval and folds the
+// outcome into its streak, taking it out of (or back into) the pool once the
+// configured thresholds are crossed.
+type healthChecker struct {
+	spec      *HealthCheckSpec
+	upstreams []*upstream
+
+	expectedStatus *regexp.Regexp
+	expectedBody   *regexp.Regexp
+
+	client *http.Client
+
+	done chan struct{}
+}
+
+func newHealthChecker(spec *HealthCheckSpec, upstreams []*upstream) *healthChecker {
+	expectedStatus := spec.ExpectedStatus
+	if expectedStatus == "" {
+		expectedStatus = defaultExpectedStatus
+	}
+
+	hc := &healthChecker{
+		spec:           spec,
+		upstreams:      upstreams,
+		expectedStatus: regexp.MustCompile(expectedStatus),
+		client: &http.Client{
+			Timeout: timeoutOrDefault(spec.TimeoutSec, defaultTimeoutSec),
+		},
+		done: make(chan struct{}),
+	}
+
+	if spec.ExpectedBody != "" {
+		hc.expectedBody = regexp.MustCompile(spec.ExpectedBody)
+	}
+
+	return hc
+}
+
+func timeoutOrDefault(sec, def int) time.Duration {
+	if sec <= 0 {
+		sec = def
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// run probes every upstream once per interval until close is called.
+func (hc *healthChecker) run() {
+	interval := timeoutOrDefault(hc.spec.IntervalSec, defaultIntervalSec)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	unhealthyThreshold := int32(hc.spec.UnhealthyThreshold)
+	if unhealthyThreshold <= 0 {
+		unhealthyThreshold = defaultUnhealthyThreshold
+	}
+	healthyThreshold := int32(hc.spec.HealthyThreshold)
+	if healthyThreshold <= 0 {
+		healthyThreshold = defaultHealthyThreshold
+	}
+
+	for {
+		select {
+		case <-hc.done:
+			return
+		case <-ticker.C:
+			for _, u := range hc.upstreams {
+				ok := hc.probe(u)
+				u.recordProbe(ok, unhealthyThreshold, healthyThreshold)
+			}
+		}
+	}
+}
+
+func (hc *healthChecker) probe(u *upstream) bool {
+	resp, err := hc.client.Get(u.url + hc.spec.Path)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	if !hc.expectedStatus.MatchString(strconv.Itoa(resp.StatusCode)) {
+		return false
+	}
+
+	if hc.expectedBody == nil {
+		io.Copy(io.Discard, resp.Body)
+		return true
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false
+	}
+	return hc.expectedBody.Match(body)
+}
+
+// close stops the health-check loop. It does not block on an in-flight probe.
+func (hc *healthChecker) close() {
+	close(hc.done)
+}
@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/megaease/easegateway/pkg/context"
+)
+
+// selector picks one healthy upstream out of candidates for ctx, or nil if
+// none are available.
+type selector func(ctx context.HTTPContext, candidates []*upstream) *upstream
+
+func newSelector(spec *Spec) selector {
+	switch spec.Policy {
+	case PolicyRandom:
+		return randomSelector
+	case PolicyLeastConn:
+		return leastConnSelector
+	case PolicyFirstHealthy:
+		return firstHealthySelector
+	case PolicyIPHash:
+		return hashSelector(func(ctx context.HTTPContext) string { return ctx.Request().RemoteAddr() })
+	case PolicyURIHash:
+		return hashSelector(func(ctx context.HTTPContext) string { return ctx.Request().Path() })
+	case PolicyHeaderHash:
+		key := spec.HeaderHashKey
+		return hashSelector(func(ctx context.HTTPContext) string { return ctx.Request().Header().Get(key) })
+	case PolicyCookie:
+		name := spec.CookieName
+		if name == "" {
+			name = defaultCookieName
+		}
+		return cookieSelector(name)
+	default:
+		return roundRobinSelector()
+	}
+}
+
+const defaultCookieName = "EG_UPSTREAM"
+
+func firstHealthySelector(_ context.HTTPContext, candidates []*upstream) *upstream {
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[0]
+}
+
+func randomSelector(_ context.HTTPContext, candidates []*upstream) *upstream {
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
+func leastConnSelector(_ context.HTTPContext, candidates []*upstream) *upstream {
+	if len(candidates) == 0 {
+		return nil
+	}
+	best := candidates[0]
+	for _, u := range candidates[1:] {
+		if u.loadInFlight() < best.loadInFlight() {
+			best = u
+		}
+	}
+	return best
+}
+
+// roundRobinSelector returns a selector closed over its own cursor, so it
+// must be created once per Backend rather than shared.
+func roundRobinSelector() selector {
+	var cursor uint64
+	return func(_ context.HTTPContext, candidates []*upstream) *upstream {
+		if len(candidates) == 0 {
+			return nil
+		}
+		next := atomic.AddUint64(&cursor, 1)
+		return candidates[next%uint64(len(candidates))]
+	}
+}
+
+func hashSelector(key func(ctx context.HTTPContext) string) selector {
+	return func(ctx context.HTTPContext, candidates []*upstream) *upstream {
+		if len(candidates) == 0 {
+			return nil
+		}
+		h := fnv.New32a()
+		h.Write([]byte(key(ctx)))
+		return candidates[h.Sum32()%uint32(len(candidates))]
+	}
+}
+
+// cookieSelector sticks a client to the upstream named in its cookie. When
+// the cookie is missing or names an upstream that is no longer healthy, it
+// falls back to a hash of the client address and the caller is expected to
+// set the cookie on the response to the chosen upstream's name.
+func cookieSelector(name string) selector {
+	fallback := hashSelector(func(ctx context.HTTPContext) string { return ctx.Request().RemoteAddr() })
+	return func(ctx context.HTTPContext, candidates []*upstream) *upstream {
+		cookie, err := ctx.Request().Cookie(name)
+		if err == nil {
+			for _, u := range candidates {
+				if u.url == cookie.Value {
+					return u
+				}
+			}
+		}
+		return fallback(ctx, candidates)
+	}
+}
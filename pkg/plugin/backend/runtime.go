@@ -0,0 +1,13 @@
+package backend
+
+// Runtime carries Backend state across HTTPProxy reloads. It is currently
+// empty: health and in-flight counters are cheap to rebuild from scratch,
+// but the type is kept so HTTPProxy's reload path has somewhere to pass a
+// previous generation, matching the other plugins it composes.
+type Runtime struct {
+}
+
+// NewRuntime creates a Runtime.
+func NewRuntime() *Runtime {
+	return &Runtime{}
+}
@@ -0,0 +1,66 @@
+package backend
+
+// Policy names for Spec.Policy.
+const (
+	// PolicyRoundRobin cycles through upstreams in order. It is the default.
+	PolicyRoundRobin = "round_robin"
+	// PolicyRandom picks a uniformly random healthy upstream.
+	PolicyRandom = "random"
+	// PolicyLeastConn picks the healthy upstream with the fewest in-flight requests.
+	PolicyLeastConn = "least_conn"
+	// PolicyFirstHealthy always picks the first healthy upstream in Spec.Targets order.
+	PolicyFirstHealthy = "first_healthy"
+	// PolicyIPHash picks an upstream by hashing the client's remote address.
+	PolicyIPHash = "ip_hash"
+	// PolicyURIHash picks an upstream by hashing the request path.
+	PolicyURIHash = "uri_hash"
+	// PolicyHeaderHash picks an upstream by hashing a configured request header.
+	PolicyHeaderHash = "header_hash"
+	// PolicyCookie sticks a client to the upstream recorded in a cookie, falling
+	// back to a hash of the client's remote address when it is absent or stale.
+	// Handle sets the cookie to the chosen upstream on every response.
+	PolicyCookie = "cookie"
+)
+
+type (
+	// Spec describes the Backend.
+	Spec struct {
+		// Targets is the list of upstream URLs, e.g. "http://10.0.0.1:8080".
+		Targets []string `yaml:"targets" v:"required,dive,url"`
+
+		// Policy selects how a healthy upstream is chosen for a request.
+		// Defaults to PolicyRoundRobin.
+		Policy string `yaml:"policy,omitempty"`
+		// HeaderHashKey is the header name to hash for PolicyHeaderHash.
+		HeaderHashKey string `yaml:"headerHashKey,omitempty"`
+		// CookieName is the sticky-session cookie name for PolicyCookie.
+		// Defaults to "EG_UPSTREAM".
+		CookieName string `yaml:"cookieName,omitempty"`
+
+		// HealthCheck, when set, enables active health checking of Targets.
+		HealthCheck *HealthCheckSpec `yaml:"healthCheck,omitempty"`
+	}
+
+	// HealthCheckSpec configures active upstream health checks.
+	HealthCheckSpec struct {
+		// Path is the URL path probed on every upstream, e.g. "/healthz".
+		Path string `yaml:"path" v:"required"`
+		// ExpectedStatus is a regexp matched against the probe's status code, e.g. "2..".
+		// Defaults to "2..".
+		ExpectedStatus string `yaml:"expectedStatus,omitempty"`
+		// ExpectedBody is an optional regexp matched against the probe's response body.
+		ExpectedBody string `yaml:"expectedBody,omitempty"`
+
+		// IntervalSec is the delay between probes of the same upstream, default 10.
+		IntervalSec int `yaml:"intervalSec,omitempty"`
+		// TimeoutSec is the per-probe timeout, default 3.
+		TimeoutSec int `yaml:"timeoutSec,omitempty"`
+
+		// UnhealthyThreshold is the number of consecutive failed probes before an
+		// upstream is taken out of the pool, default 3.
+		UnhealthyThreshold int `yaml:"unhealthyThreshold,omitempty"`
+		// HealthyThreshold is the number of consecutive successful probes before a
+		// previously unhealthy upstream rejoins the pool, default 2.
+		HealthyThreshold int `yaml:"healthyThreshold,omitempty"`
+	}
+)
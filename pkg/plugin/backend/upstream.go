@@ -0,0 +1,68 @@
+package backend
+
+import "sync/atomic"
+
+// upstream tracks the live state of one Spec.Targets entry: whether it is
+// currently in the pool, its consecutive probe streak, and in-flight
+// requests for PolicyLeastConn.
+type upstream struct {
+	url string
+
+	healthy int32 // 1 == true, 0 == false; accessed atomically
+	// streak is positive for N consecutive successful probes and negative
+	// for N consecutive failed ones; accessed atomically.
+	streak   int32
+	inFlight int64 // atomically
+}
+
+func newUpstream(url string) *upstream {
+	return &upstream{url: url, healthy: 1}
+}
+
+func (u *upstream) isHealthy() bool {
+	return atomic.LoadInt32(&u.healthy) == 1
+}
+
+func (u *upstream) incInFlight() {
+	atomic.AddInt64(&u.inFlight, 1)
+}
+
+func (u *upstream) decInFlight() {
+	atomic.AddInt64(&u.inFlight, -1)
+}
+
+func (u *upstream) loadInFlight() int64 {
+	return atomic.LoadInt64(&u.inFlight)
+}
+
+// recordProbe folds one health-probe outcome into the upstream's streak and
+// flips its healthy state once the configured threshold is crossed. A probe
+// that disagrees with the streak's current direction (success vs. failure)
+// resets it before counting itself, so only truly consecutive outcomes
+// accumulate.
+func (u *upstream) recordProbe(ok bool, unhealthyThreshold, healthyThreshold int32) {
+	prev := atomic.LoadInt32(&u.streak)
+
+	var streak int32
+	switch {
+	case ok && prev > 0:
+		streak = atomic.AddInt32(&u.streak, 1)
+	case ok:
+		streak = 1
+		atomic.StoreInt32(&u.streak, streak)
+	case !ok && prev < 0:
+		streak = atomic.AddInt32(&u.streak, -1)
+	default:
+		streak = -1
+		atomic.StoreInt32(&u.streak, streak)
+	}
+
+	wasHealthy := u.isHealthy()
+	if wasHealthy && !ok && -streak >= unhealthyThreshold {
+		atomic.StoreInt32(&u.healthy, 0)
+		atomic.StoreInt32(&u.streak, 0)
+	} else if !wasHealthy && ok && streak >= healthyThreshold {
+		atomic.StoreInt32(&u.healthy, 1)
+		atomic.StoreInt32(&u.streak, 0)
+	}
+}
@@ -0,0 +1,63 @@
+package backend
+
+import "testing"
+
+func TestRecordProbeTripsUnhealthyAfterThreshold(t *testing.T) {
+	u := newUpstream("http://10.0.0.1")
+
+	u.recordProbe(false, 3, 2)
+	if !u.isHealthy() {
+		t.Fatal("became unhealthy after 1 of 3 failures")
+	}
+	u.recordProbe(false, 3, 2)
+	if !u.isHealthy() {
+		t.Fatal("became unhealthy after 2 of 3 failures")
+	}
+	u.recordProbe(false, 3, 2)
+	if u.isHealthy() {
+		t.Fatal("did not become unhealthy after 3 consecutive failures")
+	}
+}
+
+func TestRecordProbeRecoversAfterThreshold(t *testing.T) {
+	u := newUpstream("http://10.0.0.1")
+	u.recordProbe(false, 3, 2)
+	u.recordProbe(false, 3, 2)
+	u.recordProbe(false, 3, 2)
+	if u.isHealthy() {
+		t.Fatal("setup: upstream should be unhealthy")
+	}
+
+	u.recordProbe(true, 3, 2)
+	if u.isHealthy() {
+		t.Fatal("recovered after 1 of 2 successes")
+	}
+	u.recordProbe(true, 3, 2)
+	if !u.isHealthy() {
+		t.Fatal("did not recover after 2 consecutive successes")
+	}
+}
+
+func TestRecordProbeResetsStreakOnDirectionChange(t *testing.T) {
+	u := newUpstream("http://10.0.0.1")
+
+	u.recordProbe(false, 3, 2)
+	u.recordProbe(false, 3, 2)
+	// A single success before the unhealthy threshold is crossed should
+	// reset the failure streak, not just pause it.
+	u.recordProbe(true, 3, 2)
+	u.recordProbe(false, 3, 2)
+	u.recordProbe(false, 3, 2)
+	if !u.isHealthy() {
+		t.Fatal("became unhealthy without 3 *consecutive* failures")
+	}
+}
+
+func TestRecordProbeTripsOnSingleFailureWithThresholdOne(t *testing.T) {
+	u := newUpstream("http://10.0.0.1")
+
+	u.recordProbe(false, 1, 1)
+	if u.isHealthy() {
+		t.Fatal("did not flip unhealthy with threshold 1")
+	}
+}
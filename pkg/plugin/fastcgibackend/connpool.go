@@ -0,0 +1,55 @@
+package fastcgibackend
+
+import "net"
+
+// defaultMaxConns is used when Spec.MaxConns is unset.
+const defaultMaxConns = 64
+
+// connPool holds idle, keep-alive FastCGI connections so repeated requests
+// can reuse a responder connection instead of dialing fresh every time. It
+// is only created when Spec.KeepConn is set; FastCGIBackend dials per
+// request otherwise.
+type connPool struct {
+	dial func() (net.Conn, error)
+	idle chan net.Conn
+}
+
+func newConnPool(max int, dial func() (net.Conn, error)) *connPool {
+	if max <= 0 {
+		max = defaultMaxConns
+	}
+	return &connPool{dial: dial, idle: make(chan net.Conn, max)}
+}
+
+// get returns an idle pooled connection if one is available, otherwise
+// dials a new one.
+func (p *connPool) get() (net.Conn, error) {
+	select {
+	case conn := <-p.idle:
+		return conn, nil
+	default:
+		return p.dial()
+	}
+}
+
+// put returns conn to the pool for reuse, closing it instead if the pool is
+// already full.
+func (p *connPool) put(conn net.Conn) {
+	select {
+	case p.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// close closes every idle pooled connection.
+func (p *connPool) close() {
+	for {
+		select {
+		case conn := <-p.idle:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
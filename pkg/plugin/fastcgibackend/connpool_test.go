@@ -0,0 +1,46 @@
+package fastcgibackend
+
+import (
+	"net"
+	"testing"
+)
+
+func TestConnPoolReusesPutConnections(t *testing.T) {
+	dials := 0
+	p := newConnPool(1, func() (net.Conn, error) {
+		dials++
+		c1, c2 := net.Pipe()
+		c2.Close()
+		return c1, nil
+	})
+
+	conn, err := p.get()
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	p.put(conn)
+
+	if _, err := p.get(); err != nil {
+		t.Fatalf("get after put: %v", err)
+	}
+	if dials != 1 {
+		t.Errorf("dials = %d, want 1 (second get should have reused the pooled conn)", dials)
+	}
+}
+
+func TestConnPoolClosesOverflow(t *testing.T) {
+	p := newConnPool(1, func() (net.Conn, error) {
+		c1, _ := net.Pipe()
+		return c1, nil
+	})
+
+	a, _ := p.get()
+	b, _ := p.get()
+
+	p.put(a)
+	p.put(b) // pool capacity is 1, so this one must be closed rather than queued
+
+	if len(p.idle) != 1 {
+		t.Fatalf("idle = %d, want 1", len(p.idle))
+	}
+}
@@ -0,0 +1,288 @@
+// Package fastcgibackend implements a FastCGI backend for HTTPProxy, so
+// Easegress can front PHP-FPM and other FastCGI workers directly without an
+// intermediate HTTP server.
+package fastcgibackend
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/megaease/easegateway/pkg/context"
+	"github.com/megaease/easegateway/pkg/logger"
+)
+
+const (
+	defaultConnectTimeoutSec = 5
+	defaultReadTimeoutSec    = 60
+)
+
+type (
+	// FastCGIBackend is a FastCGI transport for HTTPProxy, used as a
+	// mutually exclusive alternative to backend.Backend.
+	FastCGIBackend struct {
+		spec *Spec
+		ids  *idPool
+		pool *connPool // non-nil only when spec.KeepConn is set
+
+		connectTimeout time.Duration
+		readTimeout    time.Duration
+
+		splitPathInfo *regexp.Regexp
+
+		onResponseGot []ResponseGotFunc
+	}
+
+	// ResponseGotFunc is called after a response has been read back into
+	// ctx.Response(), mirroring backend.Backend's callback of the same name.
+	ResponseGotFunc func(ctx context.HTTPContext)
+)
+
+// New creates a FastCGIBackend.
+func New(spec *Spec) *FastCGIBackend {
+	connectTimeout := time.Duration(spec.ConnectTimeoutSec) * time.Second
+	if connectTimeout <= 0 {
+		connectTimeout = defaultConnectTimeoutSec * time.Second
+	}
+	readTimeout := time.Duration(spec.ReadTimeoutSec) * time.Second
+	if readTimeout <= 0 {
+		readTimeout = defaultReadTimeoutSec * time.Second
+	}
+
+	fb := &FastCGIBackend{
+		spec:           spec,
+		ids:            newIDPool(),
+		connectTimeout: connectTimeout,
+		readTimeout:    readTimeout,
+	}
+
+	if spec.KeepConn {
+		fb.pool = newConnPool(spec.MaxConns, func() (net.Conn, error) {
+			return net.DialTimeout(spec.Network, spec.Address, fb.connectTimeout)
+		})
+	}
+
+	if spec.SplitPathInfo != "" {
+		re, err := regexp.Compile(spec.SplitPathInfo)
+		if err != nil {
+			logger.Errorf("fastcgibackend: invalid splitPathInfo %q: %v", spec.SplitPathInfo, err)
+		} else {
+			fb.splitPathInfo = re
+		}
+	}
+
+	return fb
+}
+
+// OnResponseGot registers fn to run after a response has been written into
+// ctx.Response().
+func (fb *FastCGIBackend) OnResponseGot(fn ResponseGotFunc) {
+	fb.onResponseGot = append(fb.onResponseGot, fn)
+}
+
+// Handle handles the HTTP context by round-tripping it through the FastCGI
+// responder configured in Spec.
+func (fb *FastCGIBackend) Handle(ctx context.HTTPContext) error {
+	if err := fb.roundTrip(ctx); err != nil {
+		err = fmt.Errorf("fastcgibackend: %w", err)
+		ctx.Response().SetStatusCode(502 /* Bad Gateway */)
+		ctx.Cancel(err)
+		return err
+	}
+
+	for _, fn := range fb.onResponseGot {
+		fn(ctx)
+	}
+	return nil
+}
+
+// Close closes the FastCGIBackend, releasing any pooled keep-alive
+// connections.
+func (fb *FastCGIBackend) Close() {
+	if fb.pool != nil {
+		fb.pool.close()
+	}
+}
+
+func (fb *FastCGIBackend) roundTrip(ctx context.HTTPContext) error {
+	conn, err := fb.dial()
+	if err != nil {
+		return fmt.Errorf("dial %s %s: %w", fb.spec.Network, fb.spec.Address, err)
+	}
+	keepConn := false
+	closeConn := true
+	defer func() {
+		if closeConn {
+			conn.Close()
+		}
+	}()
+
+	conn.SetDeadline(time.Now().Add(fb.readTimeout))
+
+	id := fb.ids.get()
+	defer fb.ids.put(id)
+
+	req := ctx.Request()
+
+	if fb.pool != nil {
+		keepConn = true
+	}
+	if err := writeBeginRequest(conn, id, keepConn); err != nil {
+		return fmt.Errorf("write begin request: %w", err)
+	}
+	if err := writeRecord(conn, typeParams, id, encodeParams(fb.buildParams(req)), true); err != nil {
+		return fmt.Errorf("write params: %w", err)
+	}
+
+	body := req.Body()
+	if body == nil {
+		body = io.NopCloser(strings.NewReader(""))
+	}
+	if err := fb.writeStdin(conn, id, body); err != nil {
+		return fmt.Errorf("write stdin: %w", err)
+	}
+
+	resp, err := readResponse(bufio.NewReader(conn), id)
+	if err != nil {
+		return err
+	}
+	if len(resp.stderr) > 0 {
+		logger.Errorf("fastcgibackend: stderr: %s", resp.stderr)
+	}
+	if resp.end == nil {
+		return fmt.Errorf("missing end request record")
+	}
+	if resp.end.protocolStatus != 0 {
+		return fmt.Errorf("fastcgi protocol status %d", resp.end.protocolStatus)
+	}
+
+	if keepConn {
+		conn.SetDeadline(time.Time{})
+		fb.pool.put(conn)
+		closeConn = false
+	}
+
+	return fb.writeCGIResponse(ctx, resp.stdout)
+}
+
+// dial returns a connection to the FastCGI responder, reusing one from the
+// pool when KeepConn is set.
+func (fb *FastCGIBackend) dial() (net.Conn, error) {
+	if fb.pool != nil {
+		return fb.pool.get()
+	}
+	return net.DialTimeout(fb.spec.Network, fb.spec.Address, fb.connectTimeout)
+}
+
+// writeStdin streams the request body into STDIN records, each chunked at
+// maxRecordPayload bytes, terminated by an empty STDIN record.
+func (fb *FastCGIBackend) writeStdin(conn net.Conn, id uint16, body io.Reader) error {
+	buf := make([]byte, maxRecordPayload)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			// final=false: more chunks (or at least the terminator) are
+			// still to come, so don't let writeRecord close the stream yet.
+			if werr := writeRecord(conn, typeStdin, id, buf[:n], false); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return writeRecord(conn, typeStdin, id, nil, true)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// buildParams assembles the FastCGI PARAMS env, see the CGI/1.1 and FastCGI
+// specifications for the well-known variable names.
+func (fb *FastCGIBackend) buildParams(req context.HTTPRequest) map[string]string {
+	path := req.Path()
+	if fb.spec.Index != "" && strings.HasSuffix(path, "/") {
+		path += fb.spec.Index
+	}
+	scriptName, pathInfo := path, ""
+	if fb.splitPathInfo != nil {
+		if m := fb.splitPathInfo.FindStringSubmatch(path); len(m) == 3 {
+			scriptName, pathInfo = m[1], m[2]
+		}
+	}
+
+	scriptFilename := fb.spec.ScriptFilename
+	if scriptFilename == "" {
+		scriptFilename = "{root}{path}"
+	}
+	scriptFilename = strings.NewReplacer("{root}", fb.spec.Root, "{path}", scriptName).Replace(scriptFilename)
+
+	params := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_PROTOCOL":   "HTTP/1.1",
+		"SERVER_SOFTWARE":   "easegateway",
+		"REQUEST_METHOD":    req.Method(),
+		"SCRIPT_NAME":       scriptName,
+		"SCRIPT_FILENAME":   scriptFilename,
+		"DOCUMENT_ROOT":     fb.spec.Root,
+		"PATH_INFO":         pathInfo,
+		"REQUEST_URI":       path,
+		"QUERY_STRING":      req.Query(),
+		"REMOTE_ADDR":       req.RemoteAddr(),
+		"CONTENT_TYPE":      req.Header().Get("Content-Type"),
+		"CONTENT_LENGTH":    strconv.FormatInt(req.Header().ContentLength(), 10),
+	}
+
+	for name, values := range req.Header().H() {
+		key := "HTTP_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		if len(values) > 0 {
+			params[key] = values[0]
+		}
+	}
+
+	for k, v := range fb.spec.Env {
+		params[k] = v
+	}
+
+	return params
+}
+
+// writeCGIResponse parses the CGI-style "Status:"/header block returned on
+// STDOUT and copies it into ctx.Response(), per CGI/1.1 section 6.3.
+func (fb *FastCGIBackend) writeCGIResponse(ctx context.HTTPContext, stdout []byte) error {
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(stdout)))
+	header, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("parse cgi header: %w", err)
+	}
+
+	statusCode := 200
+	if status := header.Get("Status"); status != "" {
+		if code, convErr := strconv.Atoi(strings.Fields(status)[0]); convErr == nil {
+			statusCode = code
+		}
+		header.Del("Status")
+	}
+
+	w := ctx.Response()
+	w.SetStatusCode(statusCode)
+	for name, values := range header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	body, err := io.ReadAll(tp.R)
+	if err != nil {
+		return fmt.Errorf("read cgi body: %w", err)
+	}
+	w.SetBody(bytes.NewReader(body))
+
+	return nil
+}
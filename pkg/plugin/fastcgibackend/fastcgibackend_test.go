@@ -0,0 +1,57 @@
+package fastcgibackend
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// TestWriteStdinTerminatesOnceAcrossMultipleChunks drives writeStdin with a
+// body that spans two Read calls, the scenario writeRecord's old
+// drains-to-empty-means-terminate logic got wrong: it appended a
+// zero-length terminator after *every* chunk instead of once at EOF.
+func TestWriteStdinTerminatesOnceAcrossMultipleChunks(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	body := io.MultiReader(strings.NewReader("hello "), strings.NewReader("world"))
+
+	done := make(chan error, 1)
+	go func() {
+		fb := &FastCGIBackend{}
+		err := fb.writeStdin(server, 1, body)
+		server.Close()
+		done <- err
+	}()
+
+	r := bufio.NewReader(client)
+	var got []byte
+	for {
+		h, err := readHeader(r)
+		if err != nil {
+			t.Fatalf("readHeader: %v", err)
+		}
+		if h.contentLength == 0 {
+			break // the terminator record
+		}
+		payload := make([]byte, h.contentLength)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			t.Fatalf("read payload: %v", err)
+		}
+		got = append(got, payload...)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("writeStdin: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("stdin payload = %q, want %q", got, "hello world")
+	}
+
+	// Nothing, not even another terminator, should follow.
+	if n, err := r.Read(make([]byte, 1)); err != io.EOF || n != 0 {
+		t.Errorf("unexpected trailing data after terminator (n=%d, err=%v)", n, err)
+	}
+}
@@ -0,0 +1,30 @@
+package fastcgibackend
+
+// idPool hands out FastCGI request IDs (1-65535, 0 is reserved for
+// management records) and lets them be returned for reuse once a request
+// completes, so a long-lived responder never sees IDs it must remember
+// forever.
+type idPool struct {
+	free chan uint16
+}
+
+func newIDPool() *idPool {
+	p := &idPool{free: make(chan uint16, 65535)}
+	for id := uint16(1); ; id++ {
+		p.free <- id
+		if id == 65535 {
+			// id is a uint16, so id++ above would wrap to 0 rather than
+			// exiting the loop if we let the range include this value.
+			break
+		}
+	}
+	return p
+}
+
+func (p *idPool) get() uint16 {
+	return <-p.free
+}
+
+func (p *idPool) put(id uint16) {
+	p.free <- id
+}
@@ -0,0 +1,195 @@
+package fastcgibackend
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// FastCGI record types, see the FastCGI Specification section 3.3.
+const (
+	typeBeginRequest    = 1
+	typeAbortRequest    = 2
+	typeEndRequest      = 3
+	typeParams          = 4
+	typeStdin           = 5
+	typeStdout          = 6
+	typeStderr          = 7
+	typeData            = 8
+	typeGetValues       = 9
+	typeGetValuesResult = 10
+	typeUnknownType     = 11
+
+	roleResponder = 1
+
+	flagKeepConn = 1
+
+	// maxRecordPayload is the largest payload a single FastCGI record can carry.
+	maxRecordPayload = 65535
+
+	headerLen = 8
+)
+
+// header is the 8-byte FastCGI record header.
+type header struct {
+	version       uint8
+	kind          uint8
+	requestID     uint16
+	contentLength uint16
+	paddingLength uint8
+	reserved      uint8
+}
+
+func (h *header) bytes() []byte {
+	buf := make([]byte, headerLen)
+	buf[0] = 1 // version
+	buf[1] = h.kind
+	binary.BigEndian.PutUint16(buf[2:4], h.requestID)
+	binary.BigEndian.PutUint16(buf[4:6], h.contentLength)
+	buf[6] = h.paddingLength
+	buf[7] = 0
+	return buf
+}
+
+func readHeader(r io.Reader) (*header, error) {
+	buf := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return &header{
+		version:       buf[0],
+		kind:          buf[1],
+		requestID:     binary.BigEndian.Uint16(buf[2:4]),
+		contentLength: binary.BigEndian.Uint16(buf[4:6]),
+		paddingLength: buf[6],
+	}, nil
+}
+
+// writeRecord writes one FastCGI record, chunking content into at most
+// maxRecordPayload bytes per record as required by the protocol. final
+// marks content as the last piece of a STDIN/PARAMS stream, so callers that
+// stream a body across several writeRecord calls (writeStdin) only get the
+// zero-length terminator record once, on the call that actually finishes
+// the stream, instead of after every chunk.
+func writeRecord(w io.Writer, kind uint8, requestID uint16, content []byte, final bool) error {
+	wroteEmpty := len(content) == 0
+
+	for {
+		chunk := content
+		if len(chunk) > maxRecordPayload {
+			chunk = chunk[:maxRecordPayload]
+		}
+
+		h := &header{kind: kind, requestID: requestID, contentLength: uint16(len(chunk))}
+		if _, err := w.Write(h.bytes()); err != nil {
+			return err
+		}
+		if len(chunk) > 0 {
+			if _, err := w.Write(chunk); err != nil {
+				return err
+			}
+		}
+
+		content = content[len(chunk):]
+		if len(content) == 0 {
+			break
+		}
+	}
+
+	// A zero-length record terminates STDIN/PARAMS streams. Skip it if the
+	// loop above already wrote one (content was empty to begin with).
+	if final && !wroteEmpty && (kind == typeStdin || kind == typeParams) {
+		if _, err := w.Write((&header{kind: kind, requestID: requestID}).bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBeginRequest writes the BEGIN_REQUEST record that starts a request.
+func writeBeginRequest(w io.Writer, requestID uint16, keepConn bool) error {
+	content := make([]byte, 8)
+	binary.BigEndian.PutUint16(content[0:2], roleResponder)
+	if keepConn {
+		content[2] = flagKeepConn
+	}
+	return writeRecord(w, typeBeginRequest, requestID, content, true)
+}
+
+// encodeParams encodes a set of name/value pairs per FastCGI PARAMS framing.
+func encodeParams(params map[string]string) []byte {
+	buf := make([]byte, 0, 256)
+	for name, value := range params {
+		buf = append(buf, encodeParamLength(len(name))...)
+		buf = append(buf, encodeParamLength(len(value))...)
+		buf = append(buf, name...)
+		buf = append(buf, value...)
+	}
+	return buf
+}
+
+func encodeParamLength(n int) []byte {
+	if n < 128 {
+		return []byte{byte(n)}
+	}
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, uint32(n)|1<<31)
+	return buf
+}
+
+// endRequestResult is the decoded payload of an END_REQUEST record.
+type endRequestResult struct {
+	appStatus      uint32
+	protocolStatus uint8
+}
+
+// response is what readResponse decodes off the wire for a single request ID.
+type response struct {
+	stdout []byte
+	stderr []byte
+	end    *endRequestResult
+}
+
+// readResponse demultiplexes STDOUT/STDERR/END_REQUEST records for requestID,
+// ignoring records for other request IDs (there should be none since each
+// connection in this client is dedicated to one in-flight request).
+func readResponse(r *bufio.Reader, requestID uint16) (*response, error) {
+	resp := &response{}
+	for {
+		h, err := readHeader(r)
+		if err != nil {
+			return nil, fmt.Errorf("fastcgi: read header: %w", err)
+		}
+
+		content := make([]byte, h.contentLength)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, fmt.Errorf("fastcgi: read content: %w", err)
+		}
+		if h.paddingLength > 0 {
+			if _, err := io.CopyN(io.Discard, r, int64(h.paddingLength)); err != nil {
+				return nil, fmt.Errorf("fastcgi: read padding: %w", err)
+			}
+		}
+
+		if h.requestID != requestID {
+			continue
+		}
+
+		switch h.kind {
+		case typeStdout:
+			resp.stdout = append(resp.stdout, content...)
+		case typeStderr:
+			resp.stderr = append(resp.stderr, content...)
+		case typeEndRequest:
+			if len(content) < 8 {
+				return nil, fmt.Errorf("fastcgi: short END_REQUEST record")
+			}
+			resp.end = &endRequestResult{
+				appStatus:      binary.BigEndian.Uint32(content[0:4]),
+				protocolStatus: content[4],
+			}
+			return resp, nil
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package fastcgibackend
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	h := &header{kind: typeStdout, requestID: 42, contentLength: 7, paddingLength: 1}
+
+	got, err := readHeader(bytes.NewReader(h.bytes()))
+	if err != nil {
+		t.Fatalf("readHeader: %v", err)
+	}
+	if got.kind != h.kind || got.requestID != h.requestID || got.contentLength != h.contentLength || got.paddingLength != h.paddingLength {
+		t.Errorf("readHeader round trip = %+v, want %+v", got, h)
+	}
+}
+
+func TestWriteRecordChunksOversizedContent(t *testing.T) {
+	content := bytes.Repeat([]byte{'x'}, maxRecordPayload+100)
+
+	var buf bytes.Buffer
+	if err := writeRecord(&buf, typeStdin, 1, content, true); err != nil {
+		t.Fatalf("writeRecord: %v", err)
+	}
+
+	r := bufio.NewReader(&buf)
+
+	h1, err := readHeader(r)
+	if err != nil {
+		t.Fatalf("read first header: %v", err)
+	}
+	if h1.contentLength != maxRecordPayload {
+		t.Errorf("first record contentLength = %d, want %d", h1.contentLength, maxRecordPayload)
+	}
+	if _, err := r.Discard(int(h1.contentLength)); err != nil {
+		t.Fatalf("discard first payload: %v", err)
+	}
+
+	h2, err := readHeader(r)
+	if err != nil {
+		t.Fatalf("read second header: %v", err)
+	}
+	if h2.contentLength != 100 {
+		t.Errorf("second record contentLength = %d, want 100", h2.contentLength)
+	}
+	if _, err := r.Discard(int(h2.contentLength)); err != nil {
+		t.Fatalf("discard second payload: %v", err)
+	}
+
+	// STDIN is terminated by a zero-length record.
+	h3, err := readHeader(r)
+	if err != nil {
+		t.Fatalf("read terminator header: %v", err)
+	}
+	if h3.contentLength != 0 {
+		t.Errorf("terminator contentLength = %d, want 0", h3.contentLength)
+	}
+
+	if r.Buffered() != 0 {
+		t.Errorf("%d unexpected trailing bytes", r.Buffered())
+	}
+}
+
+func TestEncodeParamsRoundTripsThroughLength(t *testing.T) {
+	short := "short value"
+	long := string(bytes.Repeat([]byte{'y'}, 200)) // forces the 4-byte length form
+
+	buf := encodeParams(map[string]string{"SHORT": short, "LONG": long})
+
+	got := map[string]string{}
+	for len(buf) > 0 {
+		nameLen, n1 := decodeParamLength(buf)
+		buf = buf[n1:]
+		valueLen, n2 := decodeParamLength(buf)
+		buf = buf[n2:]
+
+		name := string(buf[:nameLen])
+		buf = buf[nameLen:]
+		value := string(buf[:valueLen])
+		buf = buf[valueLen:]
+
+		got[name] = value
+	}
+
+	if got["SHORT"] != short {
+		t.Errorf("SHORT = %q, want %q", got["SHORT"], short)
+	}
+	if got["LONG"] != long {
+		t.Errorf("LONG mismatch: got %d bytes, want %d", len(got["LONG"]), len(long))
+	}
+}
+
+// decodeParamLength mirrors encodeParamLength's framing for the test above:
+// a high bit on the first byte means a 4-byte big-endian length follows.
+func decodeParamLength(buf []byte) (length, consumed int) {
+	if buf[0]&0x80 == 0 {
+		return int(buf[0]), 1
+	}
+	n := binary.BigEndian.Uint32(buf[0:4]) &^ (1 << 31)
+	return int(n), 4
+}
+
+func mustWriteRecord(t *testing.T, buf *bytes.Buffer, kind uint8, requestID uint16, content []byte) {
+	t.Helper()
+	if err := writeRecord(buf, kind, requestID, content, true); err != nil {
+		t.Fatalf("writeRecord: %v", err)
+	}
+}
+
+func TestReadResponseDemultiplexesAndStopsAtEndRequest(t *testing.T) {
+	var buf bytes.Buffer
+
+	// A record for a different request ID must be ignored.
+	mustWriteRecord(t, &buf, typeStdout, 99, []byte("not for us"))
+	mustWriteRecord(t, &buf, typeStdout, 7, []byte("hello "))
+	mustWriteRecord(t, &buf, typeStdout, 7, []byte("world"))
+	mustWriteRecord(t, &buf, typeStderr, 7, []byte("warn"))
+
+	endContent := make([]byte, 8)
+	binary.BigEndian.PutUint32(endContent[0:4], 0)
+	mustWriteRecord(t, &buf, typeEndRequest, 7, endContent)
+
+	resp, err := readResponse(bufio.NewReader(&buf), 7)
+	if err != nil {
+		t.Fatalf("readResponse: %v", err)
+	}
+	if string(resp.stdout) != "hello world" {
+		t.Errorf("stdout = %q, want %q", resp.stdout, "hello world")
+	}
+	if string(resp.stderr) != "warn" {
+		t.Errorf("stderr = %q, want %q", resp.stderr, "warn")
+	}
+	if resp.end == nil || resp.end.appStatus != 0 {
+		t.Errorf("end = %+v, want appStatus 0", resp.end)
+	}
+}
@@ -0,0 +1,34 @@
+package fastcgibackend
+
+// Spec describes the FastCGIBackend.
+type Spec struct {
+	// Network is the network to dial the FastCGI responder, e.g. "tcp" or "unix".
+	Network string `yaml:"network" v:"required"`
+	// Address is the FastCGI responder address, e.g. "127.0.0.1:9000" or a unix socket path.
+	Address string `yaml:"address" v:"required"`
+
+	// Root is DOCUMENT_ROOT passed to the FastCGI responder.
+	Root string `yaml:"root" v:"required"`
+	// Index is appended to the request path when it resolves to a directory.
+	Index string `yaml:"index,omitempty"`
+
+	// ScriptFilename is a template for SCRIPT_FILENAME, e.g. "{root}{path}".
+	// "{root}" is replaced with Root and "{path}" with the script part of the request path.
+	ScriptFilename string `yaml:"scriptFilename,omitempty"`
+	// SplitPathInfo, when set, is a regexp with two capture groups used to split
+	// the request path into the script name and PATH_INFO, e.g. `^(.+\.php)(/.*)$`.
+	SplitPathInfo string `yaml:"splitPathInfo,omitempty"`
+
+	// ConnectTimeoutSec is the dial timeout in seconds, default 5.
+	ConnectTimeoutSec int `yaml:"connectTimeoutSec,omitempty"`
+	// ReadTimeoutSec is the response read timeout in seconds, default 60.
+	ReadTimeoutSec int `yaml:"readTimeoutSec,omitempty"`
+
+	// KeepConn keeps the FastCGI connection in the pool instead of dialing per request.
+	KeepConn bool `yaml:"keepConn,omitempty"`
+	// MaxConns bounds the number of pooled connections, default 64.
+	MaxConns int `yaml:"maxConns,omitempty"`
+
+	// Env is passed through as additional FastCGI params on every request.
+	Env map[string]string `yaml:"env,omitempty"`
+}
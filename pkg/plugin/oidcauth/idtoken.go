@@ -0,0 +1,176 @@
+package oidcauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// audience is the "aud" claim, which per OpenID Connect Core 1.0 section 2
+// may be a single string or, for a token valid for multiple audiences, a
+// JSON array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return fmt.Errorf("aud: %w", err)
+	}
+	*a = audience(multi)
+	return nil
+}
+
+func (a audience) has(clientID string) bool {
+	for _, v := range a {
+		if v == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// idTokenClaims is the subset of standard OIDC ID token claims (OpenID
+// Connect Core 1.0 section 2) this plugin needs, plus passthrough of
+// whatever else the issuer sent for RequiredClaims/ClaimHeaders.
+type idTokenClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	Expiry    int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+
+	raw map[string]interface{}
+}
+
+func (c *idTokenClaims) UnmarshalJSON(data []byte) error {
+	type alias idTokenClaims
+	a := &struct{ *alias }{alias: (*alias)(c)}
+	if err := json.Unmarshal(data, a); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &c.raw)
+}
+
+func (c *idTokenClaims) stringClaim(name string) (string, bool) {
+	v, ok := c.raw[name]
+	if !ok {
+		return "", false
+	}
+	s, ok := v.(string)
+	return s, ok
+}
+
+// verifyIDToken checks the token's signature against keys and its iss/aud/
+// exp/nbf against spec, per OpenID Connect Core 1.0 section 3.1.3.7.
+func verifyIDToken(token string, keys *jwksCache, spec *Spec) (*idTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed id_token")
+	}
+
+	header := &jwtHeader{}
+	headerJSON, err := base64URLDecode(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("decode header: %w", err)
+	}
+	if err := json.Unmarshal(headerJSON, header); err != nil {
+		return nil, fmt.Errorf("parse header: %w", err)
+	}
+
+	key, ok := keys.key(header.Kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", header.Kid)
+	}
+
+	signature, err := base64URLDecode(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decode signature: %w", err)
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, fmt.Errorf("verify signature: %w", err)
+	}
+
+	payloadJSON, err := base64URLDecode(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("decode payload: %w", err)
+	}
+	claims := &idTokenClaims{}
+	if err := json.Unmarshal(payloadJSON, claims); err != nil {
+		return nil, fmt.Errorf("parse payload: %w", err)
+	}
+
+	if claims.Issuer != spec.Issuer {
+		return nil, fmt.Errorf("unexpected iss %q", claims.Issuer)
+	}
+	if !claims.Audience.has(spec.ClientID) {
+		return nil, fmt.Errorf("unexpected aud %v", claims.Audience)
+	}
+
+	now := time.Now().Unix()
+	// exp is mandatory per OIDC Core 1.0 section 2; a token that omits it
+	// must be rejected outright rather than treated as never expiring.
+	if claims.Expiry == 0 {
+		return nil, fmt.Errorf("id_token missing exp claim")
+	}
+	if now >= claims.Expiry {
+		return nil, fmt.Errorf("id_token expired")
+	}
+	if claims.NotBefore != 0 && now < claims.NotBefore {
+		return nil, fmt.Errorf("id_token not yet valid")
+	}
+
+	for name, want := range spec.RequiredClaims {
+		got, ok := claims.stringClaim(name)
+		if !ok || got != want {
+			return nil, fmt.Errorf("required claim %q not satisfied", name)
+		}
+	}
+
+	return claims, nil
+}
+
+func verifySignature(alg string, key interface{}, signedData, signature []byte) error {
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not RSA")
+		}
+		sum := sha256.Sum256(signedData)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], signature)
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not EC")
+		}
+		if len(signature) != 64 {
+			return fmt.Errorf("unexpected ES256 signature length %d", len(signature))
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		sum := sha256.Sum256(signedData)
+		if !ecdsa.Verify(pub, sum[:], r, s) {
+			return fmt.Errorf("signature mismatch")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+}
@@ -0,0 +1,176 @@
+package oidcauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+const testKid = "test-key"
+
+func mustSignRS256(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, err := json.Marshal(jwtHeader{Alg: "RS256", Kid: testKid})
+	if err != nil {
+		t.Fatalf("marshal header: %v", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	sum := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func testKeys(t *testing.T, pub *rsa.PublicKey) *jwksCache {
+	t.Helper()
+	c := &jwksCache{}
+	c.keys.Store(map[string]interface{}{testKid: pub})
+	return c
+}
+
+func TestVerifyIDTokenAcceptsValidToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	spec := &Spec{Issuer: "https://issuer.example.com", ClientID: "client-1"}
+
+	token := mustSignRS256(t, key, map[string]interface{}{
+		"iss": spec.Issuer,
+		"sub": "user-1",
+		"aud": spec.ClientID,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	claims, err := verifyIDToken(token, testKeys(t, &key.PublicKey), spec)
+	if err != nil {
+		t.Fatalf("verifyIDToken: %v", err)
+	}
+	if claims.Subject != "user-1" {
+		t.Errorf("Subject = %q, want %q", claims.Subject, "user-1")
+	}
+}
+
+func TestVerifyIDTokenAcceptsArrayAudience(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	spec := &Spec{Issuer: "https://issuer.example.com", ClientID: "client-1"}
+
+	token := mustSignRS256(t, key, map[string]interface{}{
+		"iss": spec.Issuer,
+		"sub": "user-1",
+		"aud": []string{"other-client", spec.ClientID},
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	if _, err := verifyIDToken(token, testKeys(t, &key.PublicKey), spec); err != nil {
+		t.Fatalf("verifyIDToken with array aud: %v", err)
+	}
+}
+
+func TestVerifyIDTokenRejectsBadSignature(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate other key: %v", err)
+	}
+	spec := &Spec{Issuer: "https://issuer.example.com", ClientID: "client-1"}
+
+	token := mustSignRS256(t, key, map[string]interface{}{
+		"iss": spec.Issuer,
+		"sub": "user-1",
+		"aud": spec.ClientID,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	// Verify against the wrong public key, simulating a forged signature.
+	if _, err := verifyIDToken(token, testKeys(t, &other.PublicKey), spec); err == nil {
+		t.Fatal("verifyIDToken accepted a token signed by an untrusted key")
+	}
+}
+
+func TestVerifyIDTokenRejectsExpired(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	spec := &Spec{Issuer: "https://issuer.example.com", ClientID: "client-1"}
+
+	token := mustSignRS256(t, key, map[string]interface{}{
+		"iss": spec.Issuer,
+		"sub": "user-1",
+		"aud": spec.ClientID,
+		"exp": time.Now().Add(-time.Hour).Unix(),
+	})
+
+	if _, err := verifyIDToken(token, testKeys(t, &key.PublicKey), spec); err == nil {
+		t.Fatal("verifyIDToken accepted an expired token")
+	}
+}
+
+func TestVerifyIDTokenRejectsMissingExpiry(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	spec := &Spec{Issuer: "https://issuer.example.com", ClientID: "client-1"}
+
+	token := mustSignRS256(t, key, map[string]interface{}{
+		"iss": spec.Issuer,
+		"sub": "user-1",
+		"aud": spec.ClientID,
+		// exp deliberately omitted: it is mandatory per OIDC Core 1.0.
+	})
+
+	if _, err := verifyIDToken(token, testKeys(t, &key.PublicKey), spec); err == nil {
+		t.Fatal("verifyIDToken accepted a token with no exp claim")
+	}
+}
+
+func TestVerifyIDTokenRejectsWrongIssuerOrAudience(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	spec := &Spec{Issuer: "https://issuer.example.com", ClientID: "client-1"}
+
+	wrongIssuer := mustSignRS256(t, key, map[string]interface{}{
+		"iss": "https://evil.example.com",
+		"sub": "user-1",
+		"aud": spec.ClientID,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	if _, err := verifyIDToken(wrongIssuer, testKeys(t, &key.PublicKey), spec); err == nil {
+		t.Fatal("verifyIDToken accepted a token with the wrong iss")
+	}
+
+	wrongAudience := mustSignRS256(t, key, map[string]interface{}{
+		"iss": spec.Issuer,
+		"sub": "user-1",
+		"aud": "some-other-client",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	if _, err := verifyIDToken(wrongAudience, testKeys(t, &key.PublicKey), spec); err == nil {
+		t.Fatal("verifyIDToken accepted a token with the wrong aud")
+	}
+}
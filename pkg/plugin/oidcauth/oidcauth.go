@@ -0,0 +1,244 @@
+// Package oidcauth implements an OIDC/OAuth2 authorization-code-with-PKCE
+// login flow as an HTTPProxy pre-handle plugin, so HTTPProxy can require a
+// verified session before traffic reaches validator/rateLimiter/backend.
+package oidcauth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/megaease/easegateway/pkg/context"
+	"github.com/megaease/easegateway/pkg/logger"
+)
+
+const (
+	defaultCookieName     = "EG_SESSION"
+	pendingCookieName     = "EG_OIDC_PENDING"
+	defaultSessionTTLSec  = 3600
+	defaultJWKSRefreshSec = 3600
+	pendingLoginTTLSec    = 300
+)
+
+// OIDCAuth is the OIDCAuth plugin.
+type OIDCAuth struct {
+	spec *Spec
+
+	discovery *discoveryDocument
+	jwks      *jwksCache
+	sealer    *sealer
+
+	redirectPath string
+}
+
+// New creates an OIDCAuth. It fetches the issuer's discovery document and
+// starts the background JWKS refresh loop.
+func New(spec *Spec) *OIDCAuth {
+	o := &OIDCAuth{spec: spec}
+
+	doc, err := fetchDiscoveryDocument(spec.Issuer)
+	if err != nil {
+		logger.Errorf("oidcauth: discovery failed for issuer %s: %v", spec.Issuer, err)
+		doc = &discoveryDocument{}
+	}
+	o.discovery = doc
+
+	refresh := time.Duration(spec.JWKSRefreshIntervalSec) * time.Second
+	if refresh <= 0 {
+		refresh = defaultJWKSRefreshSec * time.Second
+	}
+	o.jwks = newJWKSCache(doc.JWKSURI, refresh)
+
+	s, err := newSealer(spec.CookieSecret)
+	if err != nil {
+		logger.Errorf("oidcauth: %v", err)
+	}
+	o.sealer = s
+
+	if u, err := url.Parse(spec.RedirectURL); err == nil {
+		o.redirectPath = u.Path
+	}
+
+	return o
+}
+
+// Handle enforces the login flow: it lets a request with a valid session
+// cookie through (injecting ClaimHeaders), completes the authorization-code
+// callback, or starts a new PKCE login by redirecting to the issuer.
+func (o *OIDCAuth) Handle(ctx context.HTTPContext) {
+	req := ctx.Request()
+	w := ctx.Response()
+
+	if o.redirectPath != "" && req.Path() == o.redirectPath {
+		o.handleCallback(ctx)
+		return
+	}
+
+	cookieName := o.spec.CookieName
+	if cookieName == "" {
+		cookieName = defaultCookieName
+	}
+
+	if cookie, err := req.Cookie(cookieName); err == nil {
+		sess := &session{}
+		if err := o.sealer.open(cookie.Value, sess); err == nil && sess.Expiry > time.Now().Unix() {
+			o.injectClaimHeaders(req, sess)
+			return
+		}
+	}
+
+	if err := o.redirectToLogin(ctx); err != nil {
+		w.SetStatusCode(http.StatusUnauthorized)
+		ctx.Cancel(fmt.Errorf("oidcauth: %w", err))
+		return
+	}
+
+	w.SetStatusCode(http.StatusFound)
+	ctx.Cancel(fmt.Errorf("oidcauth: redirecting to login"))
+}
+
+func (o *OIDCAuth) redirectToLogin(ctx context.HTTPContext) error {
+	verifier, err := newPKCEVerifier()
+	if err != nil {
+		return err
+	}
+	state, err := newState()
+	if err != nil {
+		return err
+	}
+
+	pending := &pendingLogin{
+		State:        state,
+		CodeVerifier: verifier,
+		OriginalPath: ctx.Request().Path(),
+		Expiry:       time.Now().Add(pendingLoginTTLSec * time.Second).Unix(),
+	}
+	value, err := o.sealer.seal(pending)
+	if err != nil {
+		return err
+	}
+
+	w := ctx.Response()
+	w.SetCookie(&http.Cookie{
+		Name:     pendingCookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		MaxAge:   pendingLoginTTLSec,
+	})
+	w.Header().Set("Location", authorizationURL(o.discovery.AuthorizationEndpoint, o.spec, state, pkceChallengeS256(verifier)))
+
+	return nil
+}
+
+func (o *OIDCAuth) handleCallback(ctx context.HTTPContext) {
+	req := ctx.Request()
+	w := ctx.Response()
+
+	cancel := func(err error) {
+		w.SetStatusCode(http.StatusForbidden)
+		ctx.Cancel(fmt.Errorf("oidcauth: %w", err))
+	}
+
+	cookie, err := req.Cookie(pendingCookieName)
+	if err != nil {
+		cancel(fmt.Errorf("missing pending login cookie: %w", err))
+		return
+	}
+	pending := &pendingLogin{}
+	if err := o.sealer.open(cookie.Value, pending); err != nil {
+		cancel(fmt.Errorf("invalid pending login cookie: %w", err))
+		return
+	}
+	if pending.Expiry < time.Now().Unix() {
+		cancel(fmt.Errorf("pending login expired"))
+		return
+	}
+
+	query := req.Query()
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		cancel(fmt.Errorf("parse callback query: %w", err))
+		return
+	}
+	if values.Get("state") != pending.State {
+		cancel(fmt.Errorf("state mismatch"))
+		return
+	}
+
+	tok, err := exchangeCode(o.discovery.TokenEndpoint, o.spec, values.Get("code"), pending.CodeVerifier)
+	if err != nil {
+		cancel(fmt.Errorf("exchange code: %w", err))
+		return
+	}
+
+	claims, err := verifyIDToken(tok.IDToken, o.jwks, o.spec)
+	if err != nil {
+		cancel(fmt.Errorf("verify id_token: %w", err))
+		return
+	}
+
+	sess := &session{
+		Subject: claims.Subject,
+		Claims:  map[string]string{},
+		Expiry:  time.Now().Add(o.sessionTTL()).Unix(),
+	}
+	for claimName := range o.spec.ClaimHeaders {
+		if v, ok := claims.stringClaim(claimName); ok {
+			sess.Claims[claimName] = v
+		}
+	}
+
+	value, err := o.sealer.seal(sess)
+	if err != nil {
+		cancel(fmt.Errorf("seal session: %w", err))
+		return
+	}
+
+	cookieName := o.spec.CookieName
+	if cookieName == "" {
+		cookieName = defaultCookieName
+	}
+	w.SetCookie(&http.Cookie{
+		Name:     cookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		MaxAge:   int(o.sessionTTL().Seconds()),
+	})
+	w.SetCookie(&http.Cookie{Name: pendingCookieName, Value: "", Path: "/", MaxAge: -1})
+
+	w.Header().Set("Location", pending.OriginalPath)
+	w.SetStatusCode(http.StatusFound)
+	ctx.Cancel(fmt.Errorf("oidcauth: login complete, redirecting"))
+}
+
+func (o *OIDCAuth) sessionTTL() time.Duration {
+	sec := o.spec.SessionTTLSec
+	if sec <= 0 {
+		sec = defaultSessionTTLSec
+	}
+	return time.Duration(sec) * time.Second
+}
+
+func (o *OIDCAuth) injectClaimHeaders(req context.HTTPRequest, sess *session) {
+	for claimName, headerName := range o.spec.ClaimHeaders {
+		if claimName == "sub" {
+			req.Header().Set(headerName, sess.Subject)
+			continue
+		}
+		if v, ok := sess.Claims[claimName]; ok {
+			req.Header().Set(headerName, v)
+		}
+	}
+}
+
+// Close closes OIDCAuth, stopping its background JWKS refresh.
+func (o *OIDCAuth) Close() {
+	if o.jwks != nil {
+		o.jwks.close()
+	}
+}
@@ -0,0 +1,83 @@
+package oidcauth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// sealer encrypts and authenticates small JSON payloads for use as cookie
+// values, so neither the session nor the in-flight PKCE state needs to be
+// kept server-side.
+type sealer struct {
+	gcm cipher.AEAD
+}
+
+func newSealer(secret string) (*sealer, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("oidcauth: init cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("oidcauth: init gcm: %w", err)
+	}
+	return &sealer{gcm: gcm}, nil
+}
+
+func (s *sealer) seal(v interface{}) (string, error) {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, s.gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	ciphertext := s.gcm.Seal(nonce, nonce, plaintext, nil)
+	return base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+func (s *sealer) open(value string, v interface{}) error {
+	ciphertext, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("oidcauth: decode cookie: %w", err)
+	}
+
+	nonceSize := s.gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return fmt.Errorf("oidcauth: cookie too short")
+	}
+	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
+
+	plaintext, err := s.gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return fmt.Errorf("oidcauth: decrypt cookie: %w", err)
+	}
+
+	return json.Unmarshal(plaintext, v)
+}
+
+// session is the payload carried by the post-login cookie.
+type session struct {
+	Subject string            `json:"sub"`
+	Claims  map[string]string `json:"claims"`
+	Expiry  int64             `json:"exp"`
+}
+
+// pendingLogin is the payload carried by the short-lived cookie set while an
+// authorization-code flow is in flight.
+type pendingLogin struct {
+	State        string `json:"state"`
+	CodeVerifier string `json:"codeVerifier"`
+	OriginalPath string `json:"originalPath"`
+	Expiry       int64  `json:"exp"`
+}
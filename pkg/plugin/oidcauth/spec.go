@@ -0,0 +1,42 @@
+package oidcauth
+
+// Spec describes the OIDCAuth plugin.
+type Spec struct {
+	// Issuer is the OIDC issuer URL, e.g. "https://accounts.example.com".
+	// "{issuer}/.well-known/openid-configuration" is fetched at startup to
+	// discover the authorization, token and JWKS endpoints.
+	Issuer string `yaml:"issuer" v:"required,url"`
+
+	ClientID     string `yaml:"clientID" v:"required"`
+	ClientSecret string `yaml:"clientSecret" v:"required"`
+	// RedirectURL is the callback URL registered with the issuer. Its path
+	// is also used to recognize the callback request itself.
+	RedirectURL string `yaml:"redirectURL" v:"required,url"`
+	// Scopes defaults to ["openid"] if empty; "openid" is always included.
+	Scopes []string `yaml:"scopes,omitempty"`
+
+	// CookieName is the session cookie set after a successful login.
+	// Defaults to "EG_SESSION".
+	CookieName string `yaml:"cookieName,omitempty"`
+	// CookieSecret encrypts the session cookie (AES-256-GCM; the raw
+	// string is hashed with SHA-256 to derive the 32-byte key, so it can
+	// be any length).
+	CookieSecret string `yaml:"cookieSecret" v:"required"`
+	// SessionTTLSec bounds how long a session cookie is trusted regardless
+	// of the ID token's own expiry. Defaults to 3600.
+	SessionTTLSec int `yaml:"sessionTTLSec,omitempty"`
+
+	// JWKSRefreshIntervalSec is how often the issuer's signing keys are
+	// re-fetched in the background. Defaults to 3600.
+	JWKSRefreshIntervalSec int `yaml:"jwksRefreshIntervalSec,omitempty"`
+
+	// RequiredClaims, when set, must all be present and equal on the
+	// verified ID token, e.g. {"hd": "example.com"}. Only string-valued
+	// claims are compared; a claim of any other JSON type (e.g. a boolean)
+	// never satisfies a requirement.
+	RequiredClaims map[string]string `yaml:"requiredClaims,omitempty"`
+
+	// ClaimHeaders maps verified ID token claims to request headers set on
+	// the forwarded request, e.g. {"sub": "X-Auth-User"}.
+	ClaimHeaders map[string]string `yaml:"claimHeaders,omitempty"`
+}
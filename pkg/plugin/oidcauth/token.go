@@ -0,0 +1,75 @@
+package oidcauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+// exchangeCode trades an authorization code for tokens at the issuer's
+// token endpoint, per RFC 6749 section 4.1.3, including the PKCE
+// code_verifier from RFC 7636 section 4.5.
+func exchangeCode(tokenEndpoint string, spec *Spec, code, codeVerifier string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {spec.RedirectURL},
+		"client_id":     {spec.ClientID},
+		"client_secret": {spec.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+
+	resp, err := http.PostForm(tokenEndpoint, form)
+	if err != nil {
+		return nil, fmt.Errorf("post token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	tr := &tokenResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(tr); err != nil {
+		return nil, fmt.Errorf("decode token response: %w", err)
+	}
+	if tr.IDToken == "" {
+		return nil, fmt.Errorf("token response missing id_token")
+	}
+	return tr, nil
+}
+
+func authorizationURL(authEndpoint string, spec *Spec, state, codeChallenge string) string {
+	scopes := spec.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid"}
+	}
+	hasOpenID := false
+	for _, s := range scopes {
+		if s == "openid" {
+			hasOpenID = true
+			break
+		}
+	}
+	if !hasOpenID {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {spec.ClientID},
+		"redirect_uri":          {spec.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	return authEndpoint + "?" + q.Encode()
+}
@@ -0,0 +1,24 @@
+package oidcauth
+
+import (
+	"crypto/elliptic"
+	"encoding/base64"
+	"fmt"
+)
+
+func base64URLDecode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+func ecCurve(crv string) (elliptic.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported ec curve %q", crv)
+	}
+}
@@ -0,0 +1,93 @@
+package outboundproxy
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/megaease/easegateway/pkg/logger"
+)
+
+const (
+	defaultCheckIntervalSec = 30
+	defaultCheckTimeoutSec  = 5
+)
+
+// checker periodically verifies that every proxy in pools can actually
+// reach ipCheckURL (and, if configured, testURLs), marking it unhealthy
+// otherwise so selection skips it until it recovers.
+type checker struct {
+	spec  *Spec
+	pools []*pool
+
+	done chan struct{}
+}
+
+func newChecker(spec *Spec, pools ...*pool) *checker {
+	return &checker{spec: spec, pools: pools, done: make(chan struct{})}
+}
+
+func (c *checker) run() {
+	interval := time.Duration(c.spec.CheckIntervalSec) * time.Second
+	if interval <= 0 {
+		interval = defaultCheckIntervalSec * time.Second
+	}
+
+	c.checkAll()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.checkAll()
+		}
+	}
+}
+
+func (c *checker) checkAll() {
+	for _, p := range c.pools {
+		for _, e := range p.entries {
+			e.setHealthy(c.check(e))
+		}
+	}
+}
+
+func (c *checker) check(e *proxyEntry) bool {
+	client := &http.Client{
+		Timeout:   timeoutOrDefault(c.spec.CheckTimeoutSec),
+		Transport: &http.Transport{Proxy: http.ProxyURL(e.url)},
+	}
+
+	if !c.probe(client, c.spec.IPCheckURL) {
+		return false
+	}
+	for _, testURL := range c.spec.TestURLs {
+		if !c.probe(client, testURL) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *checker) probe(client *http.Client, rawURL string) bool {
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		logger.Errorf("outboundproxy: probe %s failed: %v", rawURL, err)
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < http.StatusInternalServerError
+}
+
+func timeoutOrDefault(sec int) time.Duration {
+	if sec <= 0 {
+		sec = defaultCheckTimeoutSec
+	}
+	return time.Duration(sec) * time.Second
+}
+
+func (c *checker) close() {
+	close(c.done)
+}
@@ -0,0 +1,123 @@
+// Package outboundproxy routes the upstream request HTTPProxy's backend
+// makes through a pool of third-party proxies, falling back to proxies we
+// operate ourselves (or direct) for a configured set of bypass domains.
+package outboundproxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNoProxyAvailable is returned by the Transport when every eligible
+// proxy is currently unhealthy, so HTTPProxy can surface a dedicated
+// fallback reason instead of a generic backend error.
+var ErrNoProxyAvailable = errors.New("outboundproxy: no healthy proxy available")
+
+// defaultConnectTimeoutSec is used when Spec.ConnectTimeoutSec is unset.
+const defaultConnectTimeoutSec = 5
+
+// OutboundProxy is the OutboundProxy plugin.
+type OutboundProxy struct {
+	spec *Spec
+
+	ourProxies        *pool
+	thirdPartyProxies *pool
+
+	checker *checker
+
+	base http.RoundTripper
+}
+
+// New creates an OutboundProxy and starts its background health checker.
+func New(spec *Spec) (*OutboundProxy, error) {
+	connectTimeoutSec := spec.ConnectTimeoutSec
+	if connectTimeoutSec <= 0 {
+		connectTimeoutSec = defaultConnectTimeoutSec
+	}
+	connectTimeout := time.Duration(connectTimeoutSec) * time.Second
+
+	ourProxies, err := newPool(spec.OurProxies, connectTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("outboundproxy: our proxies: %w", err)
+	}
+	thirdPartyProxies, err := newPool(spec.ThirdPartyProxies, connectTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("outboundproxy: third-party proxies: %w", err)
+	}
+
+	op := &OutboundProxy{
+		spec:              spec,
+		ourProxies:        ourProxies,
+		thirdPartyProxies: thirdPartyProxies,
+		base:              http.DefaultTransport,
+	}
+
+	op.checker = newChecker(spec, ourProxies, thirdPartyProxies)
+	go op.checker.run()
+
+	return op, nil
+}
+
+// Transport returns an http.RoundTripper that backend.Backend can use in
+// place of its default transport.
+func (op *OutboundProxy) Transport() http.RoundTripper {
+	return roundTripperFunc(op.roundTrip)
+}
+
+func (op *OutboundProxy) roundTrip(req *http.Request) (*http.Response, error) {
+	bypassed := op.isBypassed(req.URL.Hostname())
+
+	e := op.selectProxy(bypassed)
+	if e == nil {
+		if bypassed {
+			// Bypass domains must never traverse ThirdPartyProxies; with no
+			// healthy OurProxies left, go direct rather than fail the request.
+			return op.base.RoundTrip(req)
+		}
+		return nil, ErrNoProxyAvailable
+	}
+
+	return e.transport.RoundTrip(req)
+}
+
+// selectProxy picks the pool to draw from for a request, honoring
+// BypassDomains: bypassed requests only ever consider OurProxies.
+func (op *OutboundProxy) selectProxy(bypassed bool) *proxyEntry {
+	if bypassed {
+		return op.ourProxies.next()
+	}
+
+	if e := op.thirdPartyProxies.next(); e != nil {
+		return e
+	}
+	return op.ourProxies.next()
+}
+
+func (op *OutboundProxy) isBypassed(host string) bool {
+	for _, domain := range op.spec.BypassDomains {
+		if domain == host {
+			return true
+		}
+		if strings.HasPrefix(domain, "*.") && strings.HasSuffix(host, domain[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes OutboundProxy, stopping its background health checker and
+// releasing every proxy's pooled idle connections.
+func (op *OutboundProxy) Close() {
+	op.checker.close()
+	op.ourProxies.closeIdle()
+	op.thirdPartyProxies.closeIdle()
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,46 @@
+package outboundproxy
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRoundTripReturnsErrNoProxyAvailableWhenPoolsEmpty(t *testing.T) {
+	op := &OutboundProxy{
+		spec:              &Spec{},
+		ourProxies:        &pool{},
+		thirdPartyProxies: &pool{},
+		base:              http.DefaultTransport,
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, rtErr := op.roundTrip(req)
+	if !errors.Is(rtErr, ErrNoProxyAvailable) {
+		t.Fatalf("got %v, want ErrNoProxyAvailable", rtErr)
+	}
+}
+
+func TestRoundTripGoesDirectForBypassedHostWithNoOurProxies(t *testing.T) {
+	errDirect := errors.New("direct round trip invoked")
+	op := &OutboundProxy{
+		spec:              &Spec{BypassDomains: []string{"example.com"}},
+		ourProxies:        &pool{},
+		thirdPartyProxies: &pool{},
+		base:              roundTripperFunc(func(*http.Request) (*http.Response, error) { return nil, errDirect }),
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, rtErr := op.roundTrip(req)
+	if !errors.Is(rtErr, errDirect) {
+		t.Fatalf("bypassed host with no OurProxies should go direct, got %v", rtErr)
+	}
+}
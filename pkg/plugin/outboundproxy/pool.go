@@ -0,0 +1,87 @@
+package outboundproxy
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// proxyEntry tracks one configured proxy URL, a transport dedicated to it so
+// connections through it get reused across requests, and whether the health
+// checker currently considers it able to egress traffic.
+type proxyEntry struct {
+	rawURL    string
+	url       *url.URL
+	transport *http.Transport
+
+	healthy int32 // 1 == true, 0 == false; accessed atomically
+}
+
+func newProxyEntry(rawURL string, connectTimeout time.Duration) (*proxyEntry, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		Proxy:       http.ProxyURL(u),
+		DialContext: (&net.Dialer{Timeout: connectTimeout}).DialContext,
+	}
+	return &proxyEntry{rawURL: rawURL, url: u, transport: transport, healthy: 1}, nil
+}
+
+func (p *proxyEntry) isHealthy() bool {
+	return atomic.LoadInt32(&p.healthy) == 1
+}
+
+func (p *proxyEntry) setHealthy(ok bool) {
+	if ok {
+		atomic.StoreInt32(&p.healthy, 1)
+	} else {
+		atomic.StoreInt32(&p.healthy, 0)
+	}
+}
+
+// pool is a rotation of proxy entries with a simple atomic round-robin
+// cursor, shared between request selection and the health checker.
+type pool struct {
+	entries []*proxyEntry
+	cursor  uint64
+}
+
+func newPool(rawURLs []string, connectTimeout time.Duration) (*pool, error) {
+	p := &pool{}
+	for _, rawURL := range rawURLs {
+		e, err := newProxyEntry(rawURL, connectTimeout)
+		if err != nil {
+			return nil, err
+		}
+		p.entries = append(p.entries, e)
+	}
+	return p, nil
+}
+
+// closeIdle releases every entry's pooled idle connections.
+func (p *pool) closeIdle() {
+	for _, e := range p.entries {
+		e.transport.CloseIdleConnections()
+	}
+}
+
+// next returns the next healthy entry in rotation, or nil if none are.
+func (p *pool) next() *proxyEntry {
+	n := len(p.entries)
+	if n == 0 {
+		return nil
+	}
+
+	start := atomic.AddUint64(&p.cursor, 1)
+	for i := 0; i < n; i++ {
+		e := p.entries[(start+uint64(i))%uint64(n)]
+		if e.isHealthy() {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package outboundproxy
+
+// Spec describes the OutboundProxy plugin.
+type Spec struct {
+	// OurProxies is the pool of proxies we operate ourselves, used as the
+	// fallback for BypassDomains and whenever ThirdPartyProxies is exhausted.
+	OurProxies []string `yaml:"ourProxies,omitempty"`
+	// ThirdPartyProxies is the pool of external proxies the upstream request
+	// is routed through by default.
+	ThirdPartyProxies []string `yaml:"thirdPartyProxies" v:"required,dive,url"`
+
+	// ConnectTimeoutSec bounds dialing through a selected proxy, default 5.
+	ConnectTimeoutSec int `yaml:"connectTimeoutSec,omitempty"`
+
+	// IPCheckURL is fetched through each proxy at startup and on
+	// CheckIntervalSec to confirm it actually egresses traffic.
+	IPCheckURL string `yaml:"ipCheckURL" v:"required,url"`
+	// TestURLs are additional representative destinations probed through
+	// each proxy to validate reachability beyond the IP check.
+	TestURLs []string `yaml:"testURLs,omitempty"`
+	// CheckIntervalSec is the delay between health checks, default 30.
+	CheckIntervalSec int `yaml:"checkIntervalSec,omitempty"`
+	// CheckTimeoutSec is the per-check timeout, default 5.
+	CheckTimeoutSec int `yaml:"checkTimeoutSec,omitempty"`
+
+	// BypassDomains lists request hosts (exact match or "*.example.com"
+	// suffix match) that must never traverse ThirdPartyProxies; they go
+	// through OurProxies, or direct if no OurProxies entry is healthy.
+	BypassDomains []string `yaml:"bypassDomains,omitempty"`
+}